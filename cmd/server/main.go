@@ -1,13 +1,26 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"gorepositorytest/internal/config"
+	"gorepositorytest/internal/logging"
+	"gorepositorytest/internal/metrics"
 	"gorepositorytest/internal/middleware"
-	"gorepositorytest/internal/models"
 	"gorepositorytest/internal/repository"
 	"gorepositorytest/internal/routes"
+	"gorepositorytest/internal/service"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -16,43 +29,225 @@ import (
 )
 
 func main() {
-	r := gin.Default()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config file] [migrate %s]\n", os.Args[0], migrateUsage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := flag.Arg(0)
+	switch command {
+	case "", "migrate":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	migrate := command == "migrate"
+
+	slog.SetDefault(logging.New(os.Stdout, "info"))
+	load := config.Load
+	if migrate {
+		// Migrations never serve requests, so they run without auth settings.
+		load = config.LoadWithoutAuth
+	}
+	cfg, err := load(*configPath)
+	if err != nil {
+		fatal("failed to load config", err)
+	}
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log.Level))
+
+	var db *gorm.DB
+	var repos repositories
+	if cfg.Database.Driver == config.DriverMemory {
+		if migrate {
+			fatal("failed to migrate", errors.New("the memory driver has no schema to migrate"))
+		}
+		slog.Warn("using the in-memory store; data is lost when the server stops")
+		repos = memoryRepositories(repository.NewMemoryStore())
+	} else {
+		db, err = initDatabase(cfg.Database)
+		if err != nil {
+			fatal("failed to initialize database", err)
+		}
+
+		if migrate {
+			err := runMigrate(db, flag.Args()[1:])
+			closeDatabase(db)
+			if err != nil {
+				fatal("failed to migrate", err)
+			}
+			return
+		}
+
+		if err := prepareSchema(db, cfg.Database.MigrationMode); err != nil {
+			fatal("failed to prepare the schema", err)
+		}
+		repos = sqlRepositories(db)
+	}
+
+	m := metrics.New()
+	if db != nil {
+		if err := registerDBMetrics(m, cfg.Database.Driver, db); err != nil {
+			fatal("failed to register database metrics", err)
+		}
+	}
+
+	authCfg, err := middleware.NewAuthConfig(cfg.Auth.Algorithm, authKey(cfg.Auth), cfg.Auth.Issuer, cfg.Auth.Audience, cfg.Auth.ClockSkew)
+	if err != nil {
+		fatal("failed to load auth config", err)
+	}
+
+	if cfg.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+	r := gin.New()
+	r.Use(middleware.Metrics(m))
 	middleware.RegisterMiddlewares(r)
+	r.Use(middleware.CORS(cfg.CORS.AllowedOrigins))
+	r.Use(middleware.QueryTimeout(cfg.Server.QueryTimeout))
 
-	db, err := initDatabase()
+	routes.SetupProductRoutes(r, authCfg, service.NewProductService(repos.products))
+	routes.SetupOrderRoutes(r, authCfg, middleware.IdempotencyConfig{
+		Store: repos.idempotency,
+		TTL:   cfg.Idempotency.TTL,
+		Lease: cfg.Idempotency.Lease,
+	}, service.NewOrderService(repos.orders, repos.uow, m))
+	routes.SetupMetricsRoutes(r, m)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", srv.Addr)
 	if err != nil {
-		log.Fatalf("failed to initialize database: %v", err)
+		fatal("failed to listen", err)
 	}
+	serveErr := serve(ctx, srv, ln, cfg.Server.ShutdownTimeout)
+	stop()
 
-	productRepo := repository.NewPostgresProductRepository(db)
-	orderRepo := repository.NewPostgresOrderRepository(db)
+	if db != nil {
+		if err := closeDatabase(db); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
+	}
+	if serveErr != nil {
+		fatal("server stopped", serveErr)
+	}
+	slog.Info("server stopped")
+}
 
-	routes.SetupProductRoutes(r, productRepo)
-	routes.SetupOrderRoutes(r, orderRepo, productRepo)
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+// serve runs srv on ln until ctx is done, then stops accepting connections
+// and waits up to shutdownTimeout for in-flight requests to finish. It
+// returns only once they have, so the database can be closed afterwards.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", ln.Addr().String())
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for open requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func authKey(cfg config.AuthConfig) []byte {
+	if cfg.Algorithm == "RS256" {
+		return []byte(cfg.PublicKey)
+	}
+	return []byte(cfg.Secret)
+}
+
+// repositories is the storage the services and middleware run on.
+type repositories struct {
+	products    repository.ProductRepository
+	orders      repository.OrderRepository
+	uow         repository.UnitOfWork
+	idempotency repository.IdempotencyRepository
+}
+
+// sqlRepositories builds the GORM repositories, which serve both Postgres
+// and SQLite databases.
+func sqlRepositories(db *gorm.DB) repositories {
+	return repositories{
+		products:    repository.NewGormProductRepository(db),
+		orders:      repository.NewGormOrderRepository(db),
+		uow:         repository.NewGormUnitOfWork(db),
+		idempotency: repository.NewGormIdempotencyRepository(db),
+	}
+}
+
+func memoryRepositories(store *repository.MemoryStore) repositories {
+	return repositories{
+		products:    repository.NewMemoryProductRepository(store),
+		orders:      repository.NewMemoryOrderRepository(store),
+		uow:         repository.NewMemoryUnitOfWork(store),
+		idempotency: repository.NewMemoryIdempotencyRepository(store),
 	}
 }
 
-func initDatabase() (*gorm.DB, error) {
-	dsn := "host=localhost user=devuser password=devpassword dbname=devdb port=5432 sslmode=disable"
-	if envDSN := os.Getenv("DATABASE_DSN"); envDSN != "" {
-		dsn = envDSN
+func initDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	if cfg.Driver == config.DriverSQLite {
+		return repository.OpenSQLite(cfg.DSN, gormConfig())
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.ConnectionString()), gormConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(
-		&models.Product{},
-		&models.Order{},
-		&models.OrderItem{},
-	); err != nil {
+	sqlDB, err := db.DB()
+	if err != nil {
 		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	return db, nil
 }
+
+func gormConfig() *gorm.Config {
+	return &gorm.Config{Logger: logging.NewGormLogger(slog.Default())}
+}
+
+func registerDBMetrics(m *metrics.Metrics, name string, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return m.RegisterDB(name, sqlDB)
+}
+
+func closeDatabase(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
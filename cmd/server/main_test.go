@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startServe runs serve with handler on a free local port and returns the
+// base URL, a function that stops the server and a channel that receives
+// serve's result.
+func startServe(t *testing.T, handler http.Handler, shutdownTimeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected listener, got %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	t.Cleanup(func() { srv.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, srv, ln, shutdownTimeout) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+// blockingHandler responds only once release is closed, after signalling on
+// started that a request has arrived.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestServe(t *testing.T) {
+	t.Run("in-flight request finishes before serve returns", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		url, stop, done := startServe(t, blockingHandler(started, release), 5*time.Second)
+
+		statusCh := make(chan int, 1)
+		go func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				statusCh <- 0
+				return
+			}
+			resp.Body.Close()
+			statusCh <- resp.StatusCode
+		}()
+		<-started
+		stop()
+
+		select {
+		case err := <-done:
+			t.Fatalf("Expected serve to wait for the open request, got return %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		if status := <-statusCh; status != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, status)
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Expected nil error, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected serve to return after the request finished")
+		}
+	})
+
+	t.Run("shutdown gives up after the timeout", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		t.Cleanup(func() { close(release) })
+		url, stop, done := startServe(t, blockingHandler(started, release), 50*time.Millisecond)
+
+		go func() {
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-started
+		stop()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected serve to return once the shutdown timeout passed")
+		}
+	})
+
+	t.Run("serve error is returned without waiting for ctx", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected listener, got %v", err)
+		}
+		ln.Close()
+
+		err = serve(context.Background(), &http.Server{}, ln, time.Second)
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Expected listener error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gorepositorytest/internal/config"
+	"gorepositorytest/internal/migrations"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = "up | down | status | to <version>"
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(db *gorm.DB, args []string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate %s", migrateUsage)
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		ran, err := migrator.Up(ctx)
+		logMigrations("applied", ran)
+		return err
+	case args[0] == "down" && len(args) == 1:
+		migration, err := migrator.Down(ctx)
+		if migration != nil {
+			logMigrations("rolled back", []migrations.Migration{*migration})
+		} else if err == nil {
+			slog.Info("no migrations to roll back")
+		}
+		return err
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		ran, err := migrator.To(ctx, version)
+		logMigrations("ran", ran)
+		return err
+	case args[0] == "status" && len(args) == 1:
+		return printStatus(ctx, migrator)
+	default:
+		return fmt.Errorf("usage: migrate %s", migrateUsage)
+	}
+}
+
+func logMigrations(action string, ran []migrations.Migration) {
+	if len(ran) == 0 {
+		slog.Info("schema is up to date")
+	}
+	for _, migration := range ran {
+		slog.Info(action+" migration", "version", migration.Version, "name", migration.Name)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+// prepareSchema applies pending migrations in auto mode. In off mode the
+// schema is left alone, but the server will not start on an outdated one.
+func prepareSchema(db *gorm.DB, mode string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if mode == config.MigrationAuto {
+		ran, err := migrator.Up(ctx)
+		for _, migration := range ran {
+			slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+		return nil
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return errors.New("database schema is out of date, run the migrate up command first")
+	}
+	return nil
+}
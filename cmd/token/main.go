@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorepositorytest/internal/middleware"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Mints an HS256 token signed with JWT_SECRET for local testing.
+func main() {
+	subject := flag.String("sub", "dev-user", "subject (user ID) of the token")
+	roles := flag.String("roles", middleware.RoleCustomer, "comma separated roles (admin, staff, customer)")
+	ttl := flag.Duration("ttl", 24*time.Hour, "lifetime of the token")
+	flag.Parse()
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
+	now := time.Now()
+	claims := middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   *subject,
+			Issuer:    os.Getenv("JWT_ISSUER"),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(*ttl)),
+		},
+	}
+	if *roles != "" {
+		claims.Roles = strings.Split(*roles, ",")
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		log.Fatalf("failed to sign token: %v", err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,118 @@
+// Package apperror defines the errors shared by the repository, handler and
+// middleware layers. Each error carries a Code that the HTTP layer maps to a
+// status and a message that is safe to show to clients.
+package apperror
+
+import (
+	"context"
+	"errors"
+)
+
+type Code string
+
+const (
+	CodeBadRequest        Code = "bad_request"
+	CodeValidation        Code = "validation_failed"
+	CodeIdempotencyReused Code = "idempotency_key_reused"
+	CodeNotFound          Code = "not_found"
+	CodeConflict          Code = "conflict"
+	CodeInsufficientStock Code = "insufficient_stock"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeTimeout           Code = "timeout"
+	CodeInternal          Code = "internal"
+)
+
+// FieldError describes one field that failed validation. It is used as the
+// Details of a CodeValidation error.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Code    Code
+	Message string
+	Details any
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCause returns a copy of e that wraps err.
+func (e *Error) WithCause(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(CodeBadRequest, message)
+}
+
+func Validation(message string, details any) *Error {
+	return &Error{Code: CodeValidation, Message: message, Details: details}
+}
+
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+func InsufficientStock(message string) *Error {
+	return New(CodeInsufficientStock, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, message)
+}
+
+// Internal hides err behind a generic message.
+func Internal(err error) *Error {
+	return &Error{Code: CodeInternal, Message: "Internal server error", Err: err}
+}
+
+// Timeout reports work that was cut short by a context deadline.
+func Timeout(err error) *Error {
+	return &Error{Code: CodeTimeout, Message: "Request timed out", Err: err}
+}
+
+// From returns the *Error in err's chain, or wraps err as a timeout or
+// internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout(err)
+	}
+	return Internal(err)
+}
+
+// CodeOf returns the code of the *Error in err's chain, or CodeInternal.
+func CodeOf(err error) Code {
+	return From(err).Code
+}
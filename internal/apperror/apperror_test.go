@@ -0,0 +1,63 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	t.Run("finds wrapped error", func(t *testing.T) {
+		err := fmt.Errorf("loading order: %w", NotFound("Order not found"))
+
+		appErr := From(err)
+
+		if appErr.Code != CodeNotFound || appErr.Message != "Order not found" {
+			t.Errorf("Expected not found error, got %+v", appErr)
+		}
+	})
+
+	t.Run("hides unknown errors", func(t *testing.T) {
+		cause := errors.New("pq: connection refused")
+
+		appErr := From(cause)
+
+		if appErr.Code != CodeInternal {
+			t.Errorf("Expected code %s, got %s", CodeInternal, appErr.Code)
+		}
+
+		if appErr.Message != "Internal server error" {
+			t.Errorf("Expected generic message, got %s", appErr.Message)
+		}
+
+		if !errors.Is(appErr, cause) {
+			t.Error("Expected internal error to wrap its cause")
+		}
+	})
+
+	t.Run("reports deadlines as timeouts", func(t *testing.T) {
+		err := fmt.Errorf("loading order: %w", context.DeadlineExceeded)
+
+		appErr := From(err)
+
+		if appErr.Code != CodeTimeout || !errors.Is(appErr, context.DeadlineExceeded) {
+			t.Errorf("Expected timeout wrapping the deadline, got %+v", appErr)
+		}
+	})
+}
+
+func TestWithCause(t *testing.T) {
+	sentinel := Conflict("Order status was changed by another request")
+	cause := errors.New("0 rows affected")
+
+	err := sentinel.WithCause(cause)
+
+	if sentinel.Err != nil {
+		t.Error("Expected the original error to be left unchanged")
+	}
+
+	if !errors.Is(err, cause) || CodeOf(err) != CodeConflict {
+		t.Errorf("Expected conflict wrapping the cause, got %v", err)
+	}
+}
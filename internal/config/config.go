@@ -0,0 +1,333 @@
+// Package config loads the server settings. Every setting has a default,
+// can be set in an optional YAML file and can be overridden by an
+// environment variable, so the same binary runs in every environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // DSN is the database file, or :memory:
+	DriverMemory   = "memory" // in-process store for demos; nothing is persisted
+)
+
+const (
+	MigrationAuto = "auto" // apply pending migrations on startup
+	MigrationOff  = "off"  // refuse to start while migrations are pending
+)
+
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Log         LogConfig         `yaml:"log"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// QueryTimeout bounds each request's database work; 0 disables it.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+// DatabaseConfig selects the storage driver and describes the Postgres
+// connection. DSN, when set, is used as is and the individual connection
+// fields are ignored. The sqlite driver only reads DSN, the path of the
+// database file, and the memory driver needs none of them.
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver"`
+	DSN             string        `yaml:"dsn"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	PasswordFile    string        `yaml:"password_file"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	MigrationMode   string        `yaml:"migration_mode"`
+}
+
+// AuthConfig holds the JWT settings. Keys can be given inline or as a path
+// to a file holding them.
+type AuthConfig struct {
+	Algorithm     string        `yaml:"algorithm"` // HS256 or RS256
+	Secret        string        `yaml:"secret"`
+	SecretFile    string        `yaml:"secret_file"`
+	PublicKey     string        `yaml:"public_key"`
+	PublicKeyFile string        `yaml:"public_key_file"`
+	Issuer        string        `yaml:"issuer"`
+	Audience      string        `yaml:"audience"`
+	ClockSkew     time.Duration `yaml:"clock_skew"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // debug, info, warn or error
+}
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins browsers may call the API from; "*"
+	// allows any origin. CORS is off when the list is empty.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+	// Lease is how long a request that never finished keeps its key
+	// reserved.
+	Lease time.Duration `yaml:"lease"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+			QueryTimeout:    5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverPostgres,
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			MigrationMode:   MigrationOff,
+		},
+		Auth: AuthConfig{
+			Algorithm: "HS256",
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:   24 * time.Hour,
+			Lease: time.Minute,
+		},
+	}
+}
+
+// Load reads the config file at path, if path is not empty, applies the
+// environment on top and validates the result.
+func Load(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// LoadWithoutAuth is Load for commands that never serve requests, such as
+// migrate. The auth settings are neither read from key files nor validated.
+func LoadWithoutAuth(path string) (*Config, error) {
+	return load(path, false)
+}
+
+func load(path string, auth bool) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadKeyFiles(auth); err != nil {
+		return nil, err
+	}
+	cfg.Auth.Algorithm = strings.ToUpper(cfg.Auth.Algorithm)
+	if err := cfg.validate(auth); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadKeyFiles reads secrets given as file paths. An inline value set in the
+// same place, the config file or the environment, wins over the file. The
+// auth keys are only read when auth is set.
+func (c *Config) loadKeyFiles(auth bool) error {
+	type keyFile struct {
+		path   string
+		target *string
+	}
+	files := []keyFile{
+		{c.Database.PasswordFile, &c.Database.Password},
+	}
+	if auth {
+		files = append(files,
+			keyFile{c.Auth.SecretFile, &c.Auth.Secret},
+			keyFile{c.Auth.PublicKeyFile, &c.Auth.PublicKey},
+		)
+	}
+
+	for _, file := range files {
+		if *file.target != "" || file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read key file: %w", err)
+		}
+		*file.target = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate is Validate, leaving out the auth settings unless auth is set.
+func (c *Config) validate(auth bool) error {
+	var problems []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port (PORT) must be between 1 and 65535, got %d", c.Server.Port)
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout (HTTP_READ_TIMEOUT)", c.Server.ReadTimeout},
+		{"server.write_timeout (HTTP_WRITE_TIMEOUT)", c.Server.WriteTimeout},
+		{"server.idle_timeout (HTTP_IDLE_TIMEOUT)", c.Server.IdleTimeout},
+		{"server.shutdown_timeout (SHUTDOWN_TIMEOUT)", c.Server.ShutdownTimeout},
+		{"server.query_timeout (QUERY_TIMEOUT)", c.Server.QueryTimeout},
+		{"database.conn_max_lifetime (DB_CONN_MAX_LIFETIME)", c.Database.ConnMaxLifetime},
+		{"auth.clock_skew (JWT_CLOCK_SKEW)", c.Auth.ClockSkew},
+	}
+	for _, d := range durations {
+		check(d.value >= 0, "%s must not be negative, got %s", d.name, d.value)
+	}
+	check(c.Idempotency.TTL > 0, "idempotency.ttl (IDEMPOTENCY_TTL) must be positive, got %s", c.Idempotency.TTL)
+	check(c.Idempotency.Lease > 0, "idempotency.lease (IDEMPOTENCY_LEASE) must be positive, got %s", c.Idempotency.Lease)
+
+	db := c.Database
+	switch db.Driver {
+	case DriverPostgres:
+		if db.DSN == "" {
+			check(db.Host != "", "database.host (DB_HOST) is required")
+			check(validPort(db.Port), "database.port (DB_PORT) must be between 1 and 65535, got %d", db.Port)
+			check(db.User != "", "database.user (DB_USER) is required")
+			check(db.Name != "", "database.name (DB_NAME) is required")
+		}
+	case DriverSQLite:
+		check(db.DSN != "", "database.dsn (DATABASE_DSN) is required for sqlite, e.g. shop.db or :memory:")
+	case DriverMemory:
+	default:
+		check(false, "database.driver (DB_DRIVER) must be %q, %q or %q, got %q", DriverPostgres, DriverSQLite, DriverMemory, db.Driver)
+	}
+	check(db.MaxOpenConns >= 0, "database.max_open_conns (DB_MAX_OPEN_CONNS) must not be negative, got %d", db.MaxOpenConns)
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns (DB_MAX_IDLE_CONNS) must not be negative, got %d", db.MaxIdleConns)
+	check(db.MigrationMode == MigrationAuto || db.MigrationMode == MigrationOff,
+		"database.migration_mode (MIGRATION_MODE) must be %q or %q, got %q", MigrationAuto, MigrationOff, db.MigrationMode)
+
+	if auth {
+		switch c.Auth.Algorithm {
+		case "HS256":
+			check(c.Auth.Secret != "", "auth.secret (JWT_SECRET or JWT_SECRET_FILE) is required for HS256")
+		case "RS256":
+			if c.Auth.PublicKey == "" {
+				check(false, "auth.public_key (JWT_PUBLIC_KEY or JWT_PUBLIC_KEY_FILE) is required for RS256")
+			} else if _, err := jwt.ParseRSAPublicKeyFromPEM([]byte(c.Auth.PublicKey)); err != nil {
+				check(false, "auth.public_key (JWT_PUBLIC_KEY) is not a PEM encoded RSA public key: %v", err)
+			}
+		default:
+			check(false, "auth.algorithm (JWT_ALGORITHM) must be HS256 or RS256, got %q", c.Auth.Algorithm)
+		}
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"cors.allowed_origins (CORS_ALLOWED_ORIGINS) must be \"*\" or start with http:// or https://, got %q", origin)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// Addr is the address the HTTP server listens on.
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+// ConnectionString returns DSN, or builds a key/value connection string
+// from the individual fields.
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	parts := []string{
+		"host=" + quoteDSNValue(d.Host),
+		fmt.Sprintf("port=%d", d.Port),
+		"user=" + quoteDSNValue(d.User),
+		"dbname=" + quoteDSNValue(d.Name),
+		"sslmode=" + quoteDSNValue(d.SSLMode),
+	}
+	if d.Password != "" {
+		parts = append(parts, "password="+quoteDSNValue(d.Password))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a value for a key/value connection string when it is
+// empty or contains spaces, quotes or backslashes.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
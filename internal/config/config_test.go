@@ -0,0 +1,283 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequiredEnv sets the settings that have no default and clears the ones
+// the tests change, so the developer's environment does not leak in.
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	for _, v := range Default().envVars() {
+		t.Setenv(v.name, "")
+	}
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "shop")
+	t.Setenv("JWT_SECRET", "env-secret")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults and environment", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("PORT", "9090")
+		t.Setenv("JWT_ISSUER", "issuer")
+		t.Setenv("JWT_CLOCK_SKEW", "30s")
+		t.Setenv("CORS_ALLOWED_ORIGINS", "https://shop.example.com, http://localhost:3000")
+
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if cfg.Server.Addr() != ":9090" || cfg.Server.QueryTimeout != 5*time.Second {
+			t.Errorf("Unexpected server config: %+v", cfg.Server)
+		}
+		if cfg.Auth.Algorithm != "HS256" || cfg.Auth.Secret != "env-secret" || cfg.Auth.Issuer != "issuer" || cfg.Auth.ClockSkew != 30*time.Second {
+			t.Errorf("Unexpected auth config: %+v", cfg.Auth)
+		}
+		expectedOrigins := []string{"https://shop.example.com", "http://localhost:3000"}
+		if !reflect.DeepEqual(cfg.CORS.AllowedOrigins, expectedOrigins) {
+			t.Errorf("Expected origins %v, got %v", expectedOrigins, cfg.CORS.AllowedOrigins)
+		}
+		if cfg.Database.MigrationMode != MigrationOff || cfg.Log.Level != "info" {
+			t.Errorf("Unexpected defaults: %+v %+v", cfg.Database, cfg.Log)
+		}
+		if cfg.Idempotency.TTL != 24*time.Hour || cfg.Idempotency.Lease != time.Minute {
+			t.Errorf("Unexpected idempotency defaults: %+v", cfg.Idempotency)
+		}
+	})
+
+	t.Run("file with environment overrides", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_MAX_OPEN_CONNS", "50")
+		path := writeFile(t, "config.yaml", `
+server:
+  port: 8081
+  shutdown_timeout: 45s
+database:
+  host: db.internal
+  user: file-user
+  max_open_conns: 10
+  migration_mode: auto
+log:
+  level: debug
+`)
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if cfg.Server.Port != 8081 || cfg.Server.ShutdownTimeout != 45*time.Second {
+			t.Errorf("Expected settings from the file, got %+v", cfg.Server)
+		}
+		if cfg.Database.Host != "db.internal" || cfg.Database.MigrationMode != MigrationAuto || cfg.Log.Level != "debug" {
+			t.Errorf("Expected settings from the file, got %+v", cfg.Database)
+		}
+		if cfg.Database.User != "app" || cfg.Database.MaxOpenConns != 50 {
+			t.Errorf("Expected the environment to win, got %+v", cfg.Database)
+		}
+	})
+
+	t.Run("RS256 public key from file", func(t *testing.T) {
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		path := writeFile(t, "public.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+
+		setRequiredEnv(t)
+		t.Setenv("JWT_ALGORITHM", "rs256")
+		t.Setenv("JWT_PUBLIC_KEY_FILE", path)
+
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Auth.Algorithm != "RS256" || !strings.HasPrefix(cfg.Auth.PublicKey, "-----BEGIN PUBLIC KEY-----") {
+			t.Errorf("Expected public key to be loaded from file, got %+v", cfg.Auth)
+		}
+	})
+
+	t.Run("environment key files replace inline values from the file", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_SECRET", "")
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt", "file-secret\n"))
+		t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db", "file-password\n"))
+		path := writeFile(t, "config.yaml", `
+database:
+  password: dev-password
+auth:
+  secret: dev-secret
+`)
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Auth.Secret != "file-secret" || cfg.Database.Password != "file-password" {
+			t.Errorf("Expected the secrets from the key files, got %q and %q", cfg.Auth.Secret, cfg.Database.Password)
+		}
+	})
+
+	t.Run("inline environment values win over key files", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt", "file-secret\n"))
+		path := writeFile(t, "config.yaml", "auth:\n  secret_file: "+writeFile(t, "other", "other-secret\n")+"\n")
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Auth.Secret != "env-secret" {
+			t.Errorf("Expected the inline secret, got %q", cfg.Auth.Secret)
+		}
+	})
+
+	t.Run("auth settings are not needed without the server", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_SECRET", "")
+		t.Setenv("JWT_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		if _, err := LoadWithoutAuth(""); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if _, err := Load(""); err == nil {
+			t.Error("Expected the server config to need a secret")
+		}
+	})
+
+	t.Run("unknown file keys are rejected", func(t *testing.T) {
+		setRequiredEnv(t)
+		path := writeFile(t, "config.yaml", "server:\n  prot: 8081\n")
+
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "prot") {
+			t.Errorf("Expected error naming the unknown key, got %v", err)
+		}
+	})
+
+	t.Run("malformed environment values", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("PORT", "http")
+		t.Setenv("QUERY_TIMEOUT", "5")
+
+		_, err := Load("")
+
+		if err == nil || !strings.Contains(err.Error(), "invalid PORT") || !strings.Contains(err.Error(), "invalid QUERY_TIMEOUT") {
+			t.Errorf("Expected both variables to be reported, got %v", err)
+		}
+	})
+
+	t.Run("reports every invalid setting", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_USER", "")
+		t.Setenv("JWT_SECRET", "")
+		t.Setenv("LOG_LEVEL", "verbose")
+		t.Setenv("MIGRATION_MODE", "sometimes")
+		t.Setenv("CORS_ALLOWED_ORIGINS", "shop.example.com")
+
+		_, err := Load("")
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+
+		for _, want := range []string{"DB_USER", "JWT_SECRET", "LOG_LEVEL", "MIGRATION_MODE", "CORS_ALLOWED_ORIGINS"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %s, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("DSN replaces the connection fields", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_USER", "")
+		t.Setenv("DB_NAME", "")
+		t.Setenv("DATABASE_DSN", "postgres://app@db/shop")
+
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Database.ConnectionString() != "postgres://app@db/shop" {
+			t.Errorf("Expected the DSN, got %s", cfg.Database.ConnectionString())
+		}
+	})
+
+	t.Run("memory driver needs no connection", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_USER", "")
+		t.Setenv("DB_NAME", "")
+		t.Setenv("DB_DRIVER", "memory")
+
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Database.Driver != DriverMemory {
+			t.Errorf("Expected the memory driver, got %s", cfg.Database.Driver)
+		}
+	})
+
+	t.Run("sqlite driver needs a file", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_USER", "")
+		t.Setenv("DB_NAME", "")
+		t.Setenv("DB_DRIVER", "sqlite")
+
+		if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "DATABASE_DSN") {
+			t.Errorf("Expected error naming DATABASE_DSN, got %v", err)
+		}
+
+		t.Setenv("DATABASE_DSN", "shop.db")
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.Database.Driver != DriverSQLite || cfg.Database.ConnectionString() != "shop.db" {
+			t.Errorf("Expected the sqlite file, got %+v", cfg.Database)
+		}
+	})
+
+	t.Run("unsupported driver", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("DB_DRIVER", "mysql")
+
+		if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "DB_DRIVER") {
+			t.Errorf("Expected error naming DB_DRIVER, got %v", err)
+		}
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("JWT_ALGORITHM", "ES256")
+
+		if _, err := Load(""); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestDatabaseConfig_ConnectionString(t *testing.T) {
+	cfg := DatabaseConfig{Host: "localhost", Port: 5432, User: "app", Password: `it's secret`, Name: "shop", SSLMode: "require"}
+
+	expected := `host=localhost port=5432 user=app dbname=shop sslmode=require password='it\'s secret'`
+	if got := cfg.ConnectionString(); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
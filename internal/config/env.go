@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type envVar struct {
+	name string
+	set  func(value string) error
+}
+
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"PORT", intVar(&c.Server.Port)},
+		{"HTTP_READ_TIMEOUT", durationVar(&c.Server.ReadTimeout)},
+		{"HTTP_WRITE_TIMEOUT", durationVar(&c.Server.WriteTimeout)},
+		{"HTTP_IDLE_TIMEOUT", durationVar(&c.Server.IdleTimeout)},
+		{"SHUTDOWN_TIMEOUT", durationVar(&c.Server.ShutdownTimeout)},
+		{"QUERY_TIMEOUT", durationVar(&c.Server.QueryTimeout)},
+
+		{"DB_DRIVER", stringVar(&c.Database.Driver)},
+		{"DATABASE_DSN", stringVar(&c.Database.DSN)},
+		{"DB_HOST", stringVar(&c.Database.Host)},
+		{"DB_PORT", intVar(&c.Database.Port)},
+		{"DB_USER", stringVar(&c.Database.User)},
+		{"DB_PASSWORD", stringVar(&c.Database.Password)},
+		{"DB_PASSWORD_FILE", fileVar(&c.Database.PasswordFile, &c.Database.Password, "DB_PASSWORD")},
+		{"DB_NAME", stringVar(&c.Database.Name)},
+		{"DB_SSLMODE", stringVar(&c.Database.SSLMode)},
+		{"DB_MAX_OPEN_CONNS", intVar(&c.Database.MaxOpenConns)},
+		{"DB_MAX_IDLE_CONNS", intVar(&c.Database.MaxIdleConns)},
+		{"DB_CONN_MAX_LIFETIME", durationVar(&c.Database.ConnMaxLifetime)},
+		{"MIGRATION_MODE", stringVar(&c.Database.MigrationMode)},
+
+		{"JWT_ALGORITHM", stringVar(&c.Auth.Algorithm)},
+		{"JWT_SECRET", stringVar(&c.Auth.Secret)},
+		{"JWT_SECRET_FILE", fileVar(&c.Auth.SecretFile, &c.Auth.Secret, "JWT_SECRET")},
+		{"JWT_PUBLIC_KEY", stringVar(&c.Auth.PublicKey)},
+		{"JWT_PUBLIC_KEY_FILE", fileVar(&c.Auth.PublicKeyFile, &c.Auth.PublicKey, "JWT_PUBLIC_KEY")},
+		{"JWT_ISSUER", stringVar(&c.Auth.Issuer)},
+		{"JWT_AUDIENCE", stringVar(&c.Auth.Audience)},
+		{"JWT_CLOCK_SKEW", durationVar(&c.Auth.ClockSkew)},
+
+		{"LOG_LEVEL", stringVar(&c.Log.Level)},
+		{"CORS_ALLOWED_ORIGINS", listVar(&c.CORS.AllowedOrigins)},
+		{"IDEMPOTENCY_TTL", durationVar(&c.Idempotency.TTL)},
+		{"IDEMPOTENCY_LEASE", durationVar(&c.Idempotency.Lease)},
+	}
+}
+
+// loadEnv overrides settings with the environment variables that are set
+// and not empty.
+func (c *Config) loadEnv() error {
+	var problems []error
+	for _, v := range c.envVars() {
+		value := os.Getenv(v.name)
+		if value == "" {
+			continue
+		}
+		if err := v.set(value); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %s: %w", v.name, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func stringVar(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+// fileVar sets the path of a file holding the value of target. Unless the
+// inline variable is set as well, it also clears target, so the file
+// replaces an inline value from the config file.
+func fileVar(path, target *string, inline string) func(string) error {
+	return func(value string) error {
+		*path = value
+		if os.Getenv(inline) == "" {
+			*target = ""
+		}
+		return nil
+	}
+}
+
+func intVar(p *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
+
+// listVar reads a comma separated list.
+func listVar(p *[]string) func(string) error {
+	return func(value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*p = list
+		return nil
+	}
+}
@@ -1,136 +1,198 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/google/uuid"
-
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/middleware"
 	"gorepositorytest/internal/models"
 	"gorepositorytest/internal/repository"
+	"gorepositorytest/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+type CreateOrderItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
 type CreateOrderRequest struct {
-	OrderItems []struct {
-		ProductID uint `json:"product_id" validate:"required"`
-		Quantity  int  `json:"quantity" validate:"required,min=1"`
-	} `json:"order_items" validate:"required,min=1"`
+	OrderItems []CreateOrderItemRequest `json:"order_items" binding:"required,min=1,dive"`
 }
 
 type UpdateOrderStatusRequest struct {
-	Status string `json:"status" validate:"required,oneof=pending confirmed shipped delivered cancelled"`
+	Status string `json:"status" binding:"required,oneof=pending confirmed shipped delivered cancelled"`
 }
 
-func generateTransactionID() string {
-	return uuid.New().String()
+// currentActor returns the authenticated caller, recording an unauthorized
+// error when there is none.
+func currentActor(c *gin.Context) (service.Actor, bool) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.Error(apperror.Unauthorized("Unauthorized"))
+		return service.Actor{}, false
+	}
+	return service.Actor{
+		ID:    claims.Subject,
+		Staff: claims.HasRole(middleware.RoleStaff, middleware.RoleAdmin),
+	}, true
 }
 
-func GetAllOrders(repo repository.OrderRepository) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		orders, err := repo.GetAll()
+func parseOrderListOptions(c *gin.Context) (repository.OrderListOptions, error) {
+	var opts repository.OrderListOptions
+
+	pagination, err := parsePagination(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.Pagination = pagination
+
+	opts.SortBy, opts.SortDesc, err = parseSort(c, repository.IsValidOrderSort)
+	if err != nil {
+		return opts, err
+	}
+
+	if value := c.Query("status"); value != "" {
+		if !models.IsValidOrderStatus(value) {
+			return opts, errors.New("invalid status")
+		}
+		opts.Status = value
+	}
+
+	if value := c.Query("created_from"); value != "" {
+		from, _, err := parseTimeParam(value)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return opts, errors.New("invalid created_from")
+		}
+		opts.CreatedFrom = &from
+	}
+
+	if value := c.Query("created_to"); value != "" {
+		to, dateOnly, err := parseTimeParam(value)
+		if err != nil {
+			return opts, errors.New("invalid created_to")
+		}
+		if dateOnly {
+			// A plain date includes the whole day.
+			to = to.Add(24*time.Hour - time.Nanosecond)
+		}
+		opts.CreatedTo = &to
+	}
+
+	if value := c.Query("min_total"); value != "" {
+		total, err := models.ParseMoney(value)
+		if err != nil {
+			return opts, errors.New("invalid min_total")
+		}
+		opts.MinTotal = &total
+	}
+
+	if value := c.Query("max_total"); value != "" {
+		total, err := models.ParseMoney(value)
+		if err != nil {
+			return opts, errors.New("invalid max_total")
 		}
-		c.JSON(http.StatusOK, orders)
+		opts.MaxTotal = &total
 	}
+
+	opts.TransactionIDPrefix = c.Query("transaction_id_prefix")
+	return opts, nil
+}
+
+// parseTimeParam accepts either an RFC 3339 timestamp or a YYYY-MM-DD date.
+func parseTimeParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
 }
 
-func GetOrderByTransactionID(repo repository.OrderRepository) gin.HandlerFunc {
+func GetAllOrders(svc service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		transactionID := c.Param("transactionId")
-		if transactionID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction ID is required"})
+		actor, ok := currentActor(c)
+		if !ok {
 			return
 		}
 
-		order, err := repo.GetByTransactionID(transactionID)
+		opts, err := parseOrderListOptions(c)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			c.Error(apperror.BadRequest(err.Error()))
 			return
 		}
-		c.JSON(http.StatusOK, order)
+
+		orders, total, err := svc.List(c.Request.Context(), actor, opts)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, newPageResponse(c, orders, total, opts.Pagination))
 	}
 }
 
-func CreateOrder(orderRepo repository.OrderRepository, productRepo repository.ProductRepository) gin.HandlerFunc {
+func GetOrderByTransactionID(svc service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req CreateOrderRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		actor, ok := currentActor(c)
+		if !ok {
 			return
 		}
 
-		var totalAmount float64
-		var orderItems []models.OrderItem
-
-		for _, item := range req.OrderItems {
-			product, err := productRepo.GetByID(item.ProductID)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Product not found: " + strconv.Itoa(int(item.ProductID))})
-				return
-			}
-
-			if product.Stock < item.Quantity {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock for product: " + product.Name})
-				return
-			}
-
-			itemTotal := product.Price * float64(item.Quantity)
-			totalAmount += itemTotal
-
-			orderItems = append(orderItems, models.OrderItem{
-				ProductID: item.ProductID,
-				Quantity:  item.Quantity,
-				Price:     product.Price,
-			})
+		order, err := svc.GetByTransactionID(c.Request.Context(), actor, c.Param("transactionId"))
+		if err != nil {
+			c.Error(err)
+			return
 		}
+		c.JSON(http.StatusOK, order)
+	}
+}
 
-		order := &models.Order{
-			TransactionID: generateTransactionID(),
-			OrderItems:    orderItems,
-			TotalAmount:   totalAmount,
-			Status:        "pending",
+func CreateOrder(svc service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := currentActor(c)
+		if !ok {
+			return
 		}
 
-		if err := orderRepo.Create(order); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var req CreateOrderRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
-		// Update product stock
-		for _, item := range req.OrderItems {
-			product, _ := productRepo.GetByID(item.ProductID)
-			product.Stock -= item.Quantity
-			productRepo.Update(product)
+		items := make([]service.OrderItemInput, len(req.OrderItems))
+		for i, item := range req.OrderItems {
+			items[i] = service.OrderItemInput(item)
 		}
 
+		order, err := svc.Place(c.Request.Context(), actor, items)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 		c.JSON(http.StatusCreated, order)
 	}
 }
 
-func UpdateOrderStatus(repo repository.OrderRepository) gin.HandlerFunc {
+func UpdateOrderStatus(svc service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		idParam := c.Param("id")
-		id, err := strconv.ParseUint(idParam, 10, 32)
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			c.Error(apperror.BadRequest("Invalid order ID"))
 			return
 		}
 
 		var req UpdateOrderStatusRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		if !bindJSON(c, &req) {
 			return
 		}
 
-		if err := repo.UpdateStatus(uint(id), req.Status); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := svc.UpdateStatus(c.Request.Context(), uint(id), req.Status); err != nil {
+			c.Error(err)
 			return
 		}
-
 		c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully"})
 	}
 }
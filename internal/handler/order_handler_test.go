@@ -2,166 +2,168 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/middleware"
 	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+	"gorepositorytest/internal/service"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-// Mock order repository for testing
-type mockOrderRepository struct {
-	orders        []models.Order
-	shouldError   bool
-	errorMsg      string
-	createError   bool
-	updateError   bool
-	notFoundError bool
+// testOrderRepository is the in-memory repository with switches that make
+// its calls fail like a broken database would.
+type testOrderRepository struct {
+	repository.OrderRepository
+	store        *repository.MemoryStore
+	shouldError  bool
+	errorMsg     string
+	createError  bool
+	updateError  bool
+	lastListOpts repository.OrderListOptions
 }
 
-func (m *mockOrderRepository) GetAll() ([]models.Order, error) {
-	if m.shouldError {
-		return nil, errors.New(m.errorMsg)
+func newTestOrderRepository(t *testing.T, orders ...models.Order) *testOrderRepository {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	repo := &testOrderRepository{OrderRepository: repository.NewMemoryOrderRepository(store), store: store}
+	for _, order := range orders {
+		if err := repo.OrderRepository.Create(context.Background(), &order); err != nil {
+			t.Fatalf("Failed to create order: %v", err)
+		}
 	}
-	return m.orders, nil
+	return repo
 }
 
-func (m *mockOrderRepository) GetByTransactionID(transactionID string) (*models.Order, error) {
-	if m.notFoundError {
-		return nil, gorm.ErrRecordNotFound
-	}
-	for _, order := range m.orders {
-		if order.TransactionID == transactionID {
-			return &order, nil
-		}
+// stored reads order id straight from the store.
+func (r *testOrderRepository) stored(t *testing.T, id uint) models.Order {
+	t.Helper()
+	order, err := r.OrderRepository.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to read order %d: %v", id, err)
 	}
-	return nil, gorm.ErrRecordNotFound
+	return *order
 }
 
-func (m *mockOrderRepository) Create(order *models.Order) error {
-	if m.createError {
-		return errors.New(m.errorMsg)
+func (r *testOrderRepository) count(t *testing.T) int {
+	t.Helper()
+	orders, err := r.OrderRepository.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read orders: %v", err)
 	}
-	order.ID = uint(len(m.orders) + 1)
-	order.CreatedAt = time.Now()
-	order.UpdatedAt = time.Now()
-	m.orders = append(m.orders, *order)
-	return nil
+	return len(orders)
 }
 
-func (m *mockOrderRepository) Update(order *models.Order) error {
-	if m.updateError {
-		return errors.New(m.errorMsg)
-	}
-	for i, o := range m.orders {
-		if o.ID == order.ID {
-			m.orders[i] = *order
-			m.orders[i].UpdatedAt = time.Now()
-			return nil
+// withProducts adds products to the store the orders live in.
+func (r *testOrderRepository) withProducts(t *testing.T, products ...models.Product) *testProductRepository {
+	t.Helper()
+	repo := &testProductRepository{ProductRepository: repository.NewMemoryProductRepository(r.store), store: r.store}
+	for _, product := range products {
+		if err := repo.ProductRepository.Create(context.Background(), &product); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
 		}
 	}
-	return gorm.ErrRecordNotFound
+	return repo
 }
 
-func (m *mockOrderRepository) UpdateStatus(id uint, status string) error {
-	if m.updateError {
-		return errors.New(m.errorMsg)
+func (r *testOrderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
+	if r.shouldError {
+		return nil, errors.New(r.errorMsg)
 	}
-	for i, order := range m.orders {
-		if order.ID == id {
-			m.orders[i].Status = status
-			m.orders[i].UpdatedAt = time.Now()
-			return nil
-		}
-	}
-	return gorm.ErrRecordNotFound
+	return r.OrderRepository.GetAll(ctx)
 }
 
-// Mock product repository for testing
-type mockOrderProductRepository struct {
-	products    []models.Product
-	shouldError bool
-	errorMsg    string
-	notFound    bool
+func (r *testOrderRepository) List(ctx context.Context, opts repository.OrderListOptions) ([]models.Order, int64, error) {
+	r.lastListOpts = opts
+	if r.shouldError {
+		return nil, 0, errors.New(r.errorMsg)
+	}
+	return r.OrderRepository.List(ctx, opts)
 }
 
-func (m *mockOrderProductRepository) GetAll() ([]models.Product, error) {
-	if m.shouldError {
-		return nil, errors.New(m.errorMsg)
+func (r *testOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	if r.createError {
+		return errors.New(r.errorMsg)
 	}
-	return m.products, nil
+	return r.OrderRepository.Create(ctx, order)
 }
 
-func (m *mockOrderProductRepository) GetByID(id uint) (*models.Product, error) {
-	if m.shouldError {
-		return nil, errors.New(m.errorMsg)
+func (r *testOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	if r.updateError {
+		return errors.New(r.errorMsg)
 	}
-	if m.notFound {
-		return nil, gorm.ErrRecordNotFound
-	}
-	for _, product := range m.products {
-		if product.ID == id {
-			return &product, nil
-		}
-	}
-	return nil, gorm.ErrRecordNotFound
+	return r.OrderRepository.Update(ctx, order)
 }
 
-func (m *mockOrderProductRepository) Create(product *models.Product) error {
-	if m.shouldError {
-		return errors.New(m.errorMsg)
+func (r *testOrderRepository) UpdateStatus(ctx context.Context, id uint, from, to string) error {
+	if r.updateError {
+		return errors.New(r.errorMsg)
 	}
-	product.ID = uint(len(m.products) + 1)
-	product.CreatedAt = time.Now()
-	product.UpdatedAt = time.Now()
-	m.products = append(m.products, *product)
-	return nil
+	return r.OrderRepository.UpdateStatus(ctx, id, from, to)
 }
 
-func (m *mockOrderProductRepository) Update(product *models.Product) error {
-	if m.shouldError {
-		return errors.New(m.errorMsg)
-	}
-	for i, p := range m.products {
-		if p.ID == product.ID {
-			m.products[i] = *product
-			return nil
-		}
-	}
-	return gorm.ErrRecordNotFound
+// testUnitOfWork runs the in-memory unit of work and hands fn repositories
+// that fail like the test repositories do.
+type testUnitOfWork struct {
+	repository.UnitOfWork
+	orders   *testOrderRepository
+	products *testProductRepository
 }
 
-func (m *mockOrderProductRepository) Delete(id uint) error {
-	if m.shouldError {
-		return errors.New(m.errorMsg)
+func (u *testUnitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	return u.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		orders, products := *u.orders, *u.products
+		orders.OrderRepository, products.ProductRepository = repos.Orders, repos.Products
+		return fn(repository.Repositories{Products: &products, Orders: &orders})
+	})
+}
+
+// newOrderService builds the order service on the orders' store. products
+// may be nil when the test places no orders.
+func newOrderService(orders *testOrderRepository, products *testProductRepository) service.OrderService {
+	if products == nil {
+		products = &testProductRepository{ProductRepository: repository.NewMemoryProductRepository(orders.store), store: orders.store}
 	}
-	for i, product := range m.products {
-		if product.ID == id {
-			m.products = append(m.products[:i], m.products[i+1:]...)
-			return nil
-		}
+	return service.NewOrderService(orders, &testUnitOfWork{
+		UnitOfWork: repository.NewMemoryUnitOfWork(orders.store),
+		orders:     orders,
+		products:   products,
+	}, nil)
+}
+
+// withClaims stands in for middleware.Authenticate in handler tests
+func withClaims(subject string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		middleware.SetClaims(c, &middleware.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+			Roles:            roles,
+		})
+		c.Next()
 	}
-	return gorm.ErrRecordNotFound
 }
 
 func TestGetAllOrders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	t.Run("successful get all orders", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			orders: []models.Order{
-				{ID: 1, TransactionID: "txn-123", TotalAmount: 99.99, Status: "pending"},
-				{ID: 2, TransactionID: "txn-456", TotalAmount: 149.99, Status: "confirmed"},
-			},
-		}
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", TotalAmount: 9999, Status: "pending"},
+			models.Order{ID: 2, TransactionID: "txn-456", TotalAmount: 14999, Status: "confirmed"},
+		)
 
-		router := gin.New()
-		router.GET("/orders", GetAllOrders(mockRepo))
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
+		router.GET("/orders", GetAllOrders(newOrderService(repo, nil)))
 
 		req, _ := http.NewRequest("GET", "/orders", nil)
 		w := httptest.NewRecorder()
@@ -171,10 +173,11 @@ func TestGetAllOrders(t *testing.T) {
 			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 		}
 
-		var orders []models.Order
-		if err := json.Unmarshal(w.Body.Bytes(), &orders); err != nil {
+		var page pageResponse[models.Order]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
+		orders := page.Items
 
 		if len(orders) != 2 {
 			t.Errorf("Expected 2 orders, got %d", len(orders))
@@ -186,13 +189,13 @@ func TestGetAllOrders(t *testing.T) {
 	})
 
 	t.Run("repository error", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			shouldError: true,
-			errorMsg:    "database connection failed",
-		}
+		repo := newTestOrderRepository(t)
+		repo.shouldError = true
+		repo.errorMsg = "database connection failed"
 
-		router := gin.New()
-		router.GET("/orders", GetAllOrders(mockRepo))
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
+		router.GET("/orders", GetAllOrders(newOrderService(repo, nil)))
 
 		req, _ := http.NewRequest("GET", "/orders", nil)
 		w := httptest.NewRecorder()
@@ -207,8 +210,83 @@ func TestGetAllOrders(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "database connection failed" {
-			t.Errorf("Expected error message 'database connection failed', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
+		}
+	})
+}
+
+func TestGetAllOrders_QueryOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("filters are passed to the repository", func(t *testing.T) {
+		repo := newTestOrderRepository(t)
+
+		router := setupGin()
+		router.Use(withClaims("staff-1", middleware.RoleStaff))
+		router.GET("/orders", GetAllOrders(newOrderService(repo, nil)))
+
+		req, _ := http.NewRequest("GET", "/orders?status=shipped&created_from=2025-01-01&created_to=2025-01-31&min_total=10&max_total=99.50&transaction_id_prefix=abc&sort=-created_at&page=3&page_size=5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		opts := repo.lastListOpts
+		if opts.Status != "shipped" || opts.TransactionIDPrefix != "abc" {
+			t.Errorf("Unexpected filters: %+v", opts)
+		}
+		if opts.CustomerID != "" {
+			t.Errorf("Expected staff to list every customer, got %s", opts.CustomerID)
+		}
+		if opts.CreatedFrom == nil || !opts.CreatedFrom.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected created_from: %v", opts.CreatedFrom)
+		}
+		if opts.CreatedTo == nil || !opts.CreatedTo.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)) {
+			t.Errorf("Expected created_to to cover the whole day, got %v", opts.CreatedTo)
+		}
+		if opts.MinTotal == nil || *opts.MinTotal != 1000 || opts.MaxTotal == nil || *opts.MaxTotal != 9950 {
+			t.Errorf("Unexpected total range: %v - %v", opts.MinTotal, opts.MaxTotal)
+		}
+		if opts.SortBy != "created_at" || !opts.SortDesc {
+			t.Errorf("Expected descending created_at sort, got %s desc=%v", opts.SortBy, opts.SortDesc)
+		}
+		if opts.Page != 3 || opts.PageSize != 5 {
+			t.Errorf("Expected page 3 of size 5, got %+v", opts.Pagination)
+		}
+	})
+
+	t.Run("customer filter cannot be widened", func(t *testing.T) {
+		repo := newTestOrderRepository(t)
+
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.GET("/orders", GetAllOrders(newOrderService(repo, nil)))
+
+		req, _ := http.NewRequest("GET", "/orders?customer_id=user-2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if repo.lastListOpts.CustomerID != "user-1" {
+			t.Errorf("Expected orders scoped to 'user-1', got %s", repo.lastListOpts.CustomerID)
+		}
+	})
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{"status=lost", "created_from=yesterday", "created_to=2025-13-01", "min_total=abc", "max_total=1.001", "sort=customer_id", "page=-1", "page=4611686018427387904"} {
+			router := setupGin()
+			router.Use(withClaims("staff-1", middleware.RoleStaff))
+			router.GET("/orders", GetAllOrders(newOrderService(newTestOrderRepository(t), nil)))
+
+			req, _ := http.NewRequest("GET", "/orders?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, w.Code)
+			}
 		}
 	})
 }
@@ -217,14 +295,13 @@ func TestGetOrderByTransactionID(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	t.Run("successful get order by transaction ID", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			orders: []models.Order{
-				{ID: 1, TransactionID: "txn-123", TotalAmount: 99.99, Status: "pending"},
-			},
-		}
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", TotalAmount: 9999, Status: "pending"},
+		)
 
-		router := gin.New()
-		router.GET("/orders/:transactionId", GetOrderByTransactionID(mockRepo))
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
+		router.GET("/orders/:transactionId", GetOrderByTransactionID(newOrderService(repo, nil)))
 
 		req, _ := http.NewRequest("GET", "/orders/txn-123", nil)
 		w := httptest.NewRecorder()
@@ -245,13 +322,14 @@ func TestGetOrderByTransactionID(t *testing.T) {
 	})
 
 	t.Run("empty transaction ID parameter", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{}
+		repo := newTestOrderRepository(t)
 
-		router := gin.New()
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
 		// Use a route that can capture empty transaction ID
-		router.GET("/orders/:transactionId", GetOrderByTransactionID(mockRepo))
+		router.GET("/orders/:transactionId", GetOrderByTransactionID(newOrderService(repo, nil)))
 		// Also test the case where transaction ID could be empty string
-		router.GET("/orders/", GetOrderByTransactionID(mockRepo))
+		router.GET("/orders/", GetOrderByTransactionID(newOrderService(repo, nil)))
 
 		// Test with empty string as transaction ID parameter
 		req, _ := http.NewRequest("GET", "/orders/", nil)
@@ -265,16 +343,17 @@ func TestGetOrderByTransactionID(t *testing.T) {
 	})
 
 	t.Run("direct handler test with empty transaction ID", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{}
+		repo := newTestOrderRepository(t)
 
 		// Create a direct test using gin.Context
-		router := gin.New()
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
 
 		// Create a custom route that can simulate empty transaction ID
 		router.GET("/test", func(c *gin.Context) {
 			// Manually set an empty transaction ID parameter to test the handler logic
 			c.Params = gin.Params{gin.Param{Key: "transactionId", Value: ""}}
-			GetOrderByTransactionID(mockRepo)(c)
+			GetOrderByTransactionID(newOrderService(repo, nil))(c)
 		})
 
 		req, _ := http.NewRequest("GET", "/test", nil)
@@ -290,18 +369,17 @@ func TestGetOrderByTransactionID(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Transaction ID is required" {
-			t.Errorf("Expected error message 'Transaction ID is required', got %s", response["error"])
+		if response["message"] != "Transaction ID is required" {
+			t.Errorf("Expected error message 'Transaction ID is required', got %s", response["message"])
 		}
 	})
 
 	t.Run("order not found", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			notFoundError: true,
-		}
+		repo := newTestOrderRepository(t)
 
-		router := gin.New()
-		router.GET("/orders/:transactionId", GetOrderByTransactionID(mockRepo))
+		router := setupGin()
+		router.Use(withClaims("admin-1", middleware.RoleAdmin))
+		router.GET("/orders/:transactionId", GetOrderByTransactionID(newOrderService(repo, nil)))
 
 		req, _ := http.NewRequest("GET", "/orders/non-existent", nil)
 		w := httptest.NewRecorder()
@@ -316,8 +394,104 @@ func TestGetOrderByTransactionID(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Order not found" {
-			t.Errorf("Expected error message 'Order not found', got %s", response["error"])
+		if response["message"] != "Order not found" {
+			t.Errorf("Expected error message 'Order not found', got %s", response["message"])
+		}
+	})
+}
+
+func TestOrderOwnership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRepo := func(t *testing.T) *testOrderRepository {
+		return newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", CustomerID: "user-1", TotalAmount: 9999, Status: "pending"},
+			models.Order{ID: 2, TransactionID: "txn-456", CustomerID: "user-2", TotalAmount: 14999, Status: "confirmed"},
+		)
+	}
+
+	t.Run("customer only sees own orders", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.GET("/orders", GetAllOrders(newOrderService(newRepo(t), nil)))
+
+		req, _ := http.NewRequest("GET", "/orders", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var page pageResponse[models.Order]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+		orders := page.Items
+
+		if len(orders) != 1 || orders[0].TransactionID != "txn-123" {
+			t.Errorf("Expected only order 'txn-123', got %+v", orders)
+		}
+	})
+
+	t.Run("staff sees every order", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("staff-1", middleware.RoleStaff))
+		router.GET("/orders", GetAllOrders(newOrderService(newRepo(t), nil)))
+
+		req, _ := http.NewRequest("GET", "/orders", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var page pageResponse[models.Order]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+		orders := page.Items
+
+		if len(orders) != 2 {
+			t.Errorf("Expected 2 orders, got %d", len(orders))
+		}
+	})
+
+	t.Run("customer cannot read another customer's order", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.GET("/orders/:transactionId", GetOrderByTransactionID(newOrderService(newRepo(t), nil)))
+
+		req, _ := http.NewRequest("GET", "/orders/txn-456", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("customer reads own order", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("user-2", middleware.RoleCustomer))
+		router.GET("/orders/:transactionId", GetOrderByTransactionID(newOrderService(newRepo(t), nil)))
+
+		req, _ := http.NewRequest("GET", "/orders/txn-456", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("missing claims", func(t *testing.T) {
+		router := setupGin()
+		router.GET("/orders", GetAllOrders(newOrderService(newRepo(t), nil)))
+
+		req, _ := http.NewRequest("GET", "/orders", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, w.Code)
 		}
 	})
 }
@@ -326,22 +500,18 @@ func TestCreateOrder(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	t.Run("successful create order", func(t *testing.T) {
-		mockOrderRepo := &mockOrderRepository{}
-		mockProductRepo := &mockOrderProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Price: 10.99, Stock: 100},
-				{ID: 2, Name: "Product 2", Price: 15.99, Stock: 50},
-			},
-		}
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+			models.Product{ID: 2, Name: "Product 2", Price: 1599, Stock: 50},
+		)
 
-		router := gin.New()
-		router.POST("/orders", CreateOrder(mockOrderRepo, mockProductRepo))
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
 
 		createReq := CreateOrderRequest{
-			OrderItems: []struct {
-				ProductID uint `json:"product_id" validate:"required"`
-				Quantity  int  `json:"quantity" validate:"required,min=1"`
-			}{
+			OrderItems: []CreateOrderItemRequest{
 				{ProductID: 1, Quantity: 2},
 				{ProductID: 2, Quantity: 1},
 			},
@@ -362,26 +532,43 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		expectedTotal := 10.99*2 + 15.99*1 // 37.97
+		expectedTotal := models.Money(1099*2 + 1599*1) // 37.97
 		if order.TotalAmount != expectedTotal {
-			t.Errorf("Expected total amount %.2f, got %.2f", expectedTotal, order.TotalAmount)
+			t.Errorf("Expected total amount %s, got %s", expectedTotal, order.TotalAmount)
 		}
 
 		if order.Status != "pending" {
 			t.Errorf("Expected status 'pending', got %s", order.Status)
 		}
 
+		if order.CustomerID != "user-1" {
+			t.Errorf("Expected customer ID 'user-1', got %s", order.CustomerID)
+		}
+
 		if len(order.OrderItems) != 2 {
-			t.Errorf("Expected 2 order items, got %d", len(order.OrderItems))
+			t.Fatalf("Expected 2 order items, got %d", len(order.OrderItems))
+		}
+
+		if order.OrderItems[0].ProductName != "Product 1" || order.OrderItems[0].Price != 1099 {
+			t.Errorf("Expected item snapshot of 'Product 1' at 1099, got %s at %d", order.OrderItems[0].ProductName, order.OrderItems[0].Price)
+		}
+
+		if productRepo.stored(t, 1).Stock != 98 {
+			t.Errorf("Expected product 1 stock to be 98, got %d", productRepo.stored(t, 1).Stock)
+		}
+
+		if productRepo.stored(t, 2).Stock != 49 {
+			t.Errorf("Expected product 2 stock to be 49, got %d", productRepo.stored(t, 2).Stock)
 		}
 	})
 
 	t.Run("invalid request body", func(t *testing.T) {
-		mockOrderRepo := &mockOrderRepository{}
-		mockProductRepo := &mockOrderProductRepository{}
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t)
 
-		router := gin.New()
-		router.POST("/orders", CreateOrder(mockOrderRepo, mockProductRepo))
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
 
 		req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer([]byte("invalid json")))
 		req.Header.Set("Content-Type", "application/json")
@@ -397,25 +584,108 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Invalid request body" {
-			t.Errorf("Expected error message 'Invalid request body', got %s", response["error"])
+		if response["message"] != "Invalid request body" {
+			t.Errorf("Expected error message 'Invalid request body', got %s", response["message"])
 		}
 	})
 
-	t.Run("product not found", func(t *testing.T) {
-		mockOrderRepo := &mockOrderRepository{}
-		mockProductRepo := &mockOrderProductRepository{
-			notFound: true,
+	t.Run("validation errors", func(t *testing.T) {
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t)
+
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
+
+		reqBody := []byte(`{"order_items":[{"product_id":1,"quantity":0},{"quantity":2}]}`)
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		var response struct {
+			Message string                `json:"message"`
+			Details []apperror.FieldError `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		expected := []apperror.FieldError{
+			{Field: "order_items[0].quantity", Rule: "required", Message: "order_items[0].quantity is required"},
+			{Field: "order_items[1].product_id", Rule: "required", Message: "order_items[1].product_id is required"},
+		}
+		if !reflect.DeepEqual(response.Details, expected) {
+			t.Errorf("Expected fields %+v, got %+v", expected, response.Details)
+		}
+
+		if orderRepo.count(t) != 0 {
+			t.Errorf("Expected no order to be created, got %d", orderRepo.count(t))
+		}
+	})
+
+	t.Run("values of the wrong type", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(newTestOrderRepository(t), nil)))
+
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer([]byte(`{"order_items":[{"product_id":1,"quantity":"two"}]}`)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
 		}
 
-		router := gin.New()
-		router.POST("/orders", CreateOrder(mockOrderRepo, mockProductRepo))
+		var response struct {
+			Details []apperror.FieldError `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		// Older encoding/json versions leave the index out of the path.
+		if len(response.Details) != 1 || response.Details[0].Rule != "type" ||
+			!strings.HasPrefix(response.Details[0].Field, "order_items") ||
+			!strings.HasSuffix(response.Details[0].Message, "quantity must be a whole number") {
+			t.Errorf("Expected a type error on the quantity, got %+v", response.Details)
+		}
+	})
+
+	t.Run("empty order", func(t *testing.T) {
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(newTestOrderRepository(t), nil)))
+
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer([]byte(`{"order_items":[]}`)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if !strings.Contains(w.Body.String(), `"rule":"min"`) {
+			t.Errorf("Expected a min rule violation, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t)
+
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
 
 		createReq := CreateOrderRequest{
-			OrderItems: []struct {
-				ProductID uint `json:"product_id" validate:"required"`
-				Quantity  int  `json:"quantity" validate:"required,min=1"`
-			}{
+			OrderItems: []CreateOrderItemRequest{
 				{ProductID: 999, Quantity: 1},
 			},
 		}
@@ -435,27 +705,23 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Product not found: 999" {
-			t.Errorf("Expected error message 'Product not found: 999', got %s", response["error"])
+		if response["message"] != "Product not found: 999" {
+			t.Errorf("Expected error message 'Product not found: 999', got %s", response["message"])
 		}
 	})
 
 	t.Run("insufficient stock", func(t *testing.T) {
-		mockOrderRepo := &mockOrderRepository{}
-		mockProductRepo := &mockOrderProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Price: 10.99, Stock: 5},
-			},
-		}
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 5},
+		)
 
-		router := gin.New()
-		router.POST("/orders", CreateOrder(mockOrderRepo, mockProductRepo))
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
 
 		createReq := CreateOrderRequest{
-			OrderItems: []struct {
-				ProductID uint `json:"product_id" validate:"required"`
-				Quantity  int  `json:"quantity" validate:"required,min=1"`
-			}{
+			OrderItems: []CreateOrderItemRequest{
 				{ProductID: 1, Quantity: 10}, // Requesting more than available stock
 			},
 		}
@@ -466,8 +732,8 @@ func TestCreateOrder(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
 		}
 
 		var response map[string]string
@@ -475,30 +741,55 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Insufficient stock for product: Product 1" {
-			t.Errorf("Expected error message 'Insufficient stock for product: Product 1', got %s", response["error"])
+		if response["code"] != string(apperror.CodeInsufficientStock) {
+			t.Errorf("Expected code %s, got %s", apperror.CodeInsufficientStock, response["code"])
+		}
+
+		if response["message"] != "Insufficient stock for product: Product 1" {
+			t.Errorf("Expected error message 'Insufficient stock for product: Product 1', got %s", response["message"])
 		}
 	})
 
-	t.Run("order creation error", func(t *testing.T) {
-		mockOrderRepo := &mockOrderRepository{
-			createError: true,
-			errorMsg:    "database error",
+	t.Run("mixed currencies", func(t *testing.T) {
+		orderRepo := newTestOrderRepository(t)
+		productRepo := orderRepo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Currency: "USD", Stock: 100},
+			models.Product{ID: 2, Name: "Product 2", Price: 1599, Currency: "EUR", Stock: 50},
+		)
+
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
+
+		reqBody := []byte(`{"order_items":[{"product_id":1,"quantity":1},{"product_id":2,"quantity":1}]}`)
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 		}
-		mockProductRepo := &mockOrderProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Price: 10.99, Stock: 100},
-			},
+
+		if orderRepo.count(t) != 0 {
+			t.Errorf("Expected no order to be created, got %d", orderRepo.count(t))
 		}
+	})
 
-		router := gin.New()
-		router.POST("/orders", CreateOrder(mockOrderRepo, mockProductRepo))
+	t.Run("order creation error", func(t *testing.T) {
+		orderRepo := newTestOrderRepository(t)
+		orderRepo.createError = true
+		orderRepo.errorMsg = "database error"
+		productRepo := orderRepo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+		)
+
+		router := setupGin()
+		router.Use(withClaims("user-1", middleware.RoleCustomer))
+		router.POST("/orders", CreateOrder(newOrderService(orderRepo, productRepo)))
 
 		createReq := CreateOrderRequest{
-			OrderItems: []struct {
-				ProductID uint `json:"product_id" validate:"required"`
-				Quantity  int  `json:"quantity" validate:"required,min=1"`
-			}{
+			OrderItems: []CreateOrderItemRequest{
 				{ProductID: 1, Quantity: 1},
 			},
 		}
@@ -518,8 +809,8 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "database error" {
-			t.Errorf("Expected error message 'database error', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
 		}
 	})
 }
@@ -528,14 +819,12 @@ func TestUpdateOrderStatus(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	t.Run("successful update order status", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			orders: []models.Order{
-				{ID: 1, TransactionID: "txn-123", Status: "pending"},
-			},
-		}
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", Status: "pending"},
+		)
 
-		router := gin.New()
-		router.PUT("/orders/:id/status", UpdateOrderStatus(mockRepo))
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
 
 		updateReq := UpdateOrderStatusRequest{
 			Status: "confirmed",
@@ -562,10 +851,10 @@ func TestUpdateOrderStatus(t *testing.T) {
 	})
 
 	t.Run("invalid order ID", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{}
+		repo := newTestOrderRepository(t)
 
-		router := gin.New()
-		router.PUT("/orders/:id/status", UpdateOrderStatus(mockRepo))
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
 
 		updateReq := UpdateOrderStatusRequest{
 			Status: "confirmed",
@@ -586,16 +875,16 @@ func TestUpdateOrderStatus(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Invalid order ID" {
-			t.Errorf("Expected error message 'Invalid order ID', got %s", response["error"])
+		if response["message"] != "Invalid order ID" {
+			t.Errorf("Expected error message 'Invalid order ID', got %s", response["message"])
 		}
 	})
 
 	t.Run("invalid request body", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{}
+		repo := newTestOrderRepository(t)
 
-		router := gin.New()
-		router.PUT("/orders/:id/status", UpdateOrderStatus(mockRepo))
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
 
 		req, _ := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer([]byte("invalid json")))
 		req.Header.Set("Content-Type", "application/json")
@@ -611,19 +900,20 @@ func TestUpdateOrderStatus(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Invalid request body" {
-			t.Errorf("Expected error message 'Invalid request body', got %s", response["error"])
+		if response["message"] != "Invalid request body" {
+			t.Errorf("Expected error message 'Invalid request body', got %s", response["message"])
 		}
 	})
 
 	t.Run("update status error", func(t *testing.T) {
-		mockRepo := &mockOrderRepository{
-			updateError: true,
-			errorMsg:    "database error",
-		}
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", Status: "pending"},
+		)
+		repo.updateError = true
+		repo.errorMsg = "database error"
 
-		router := gin.New()
-		router.PUT("/orders/:id/status", UpdateOrderStatus(mockRepo))
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
 
 		updateReq := UpdateOrderStatusRequest{
 			Status: "confirmed",
@@ -644,8 +934,161 @@ func TestUpdateOrderStatus(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "database error" {
-			t.Errorf("Expected error message 'database error', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
+		}
+	})
+	t.Run("unknown status", func(t *testing.T) {
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", Status: "pending"},
+		)
+
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
+
+		req, _ := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer([]byte(`{"status":"lost"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if repo.stored(t, 1).Status != "pending" {
+			t.Errorf("Expected status to stay 'pending', got %s", repo.stored(t, 1).Status)
+		}
+	})
+
+	t.Run("order not found", func(t *testing.T) {
+		repo := newTestOrderRepository(t)
+
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
+
+		updateReq := UpdateOrderStatusRequest{
+			Status: "confirmed",
+		}
+
+		reqBody, _ := json.Marshal(updateReq)
+		req, _ := http.NewRequest("PUT", "/orders/999/status", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+
+		var response map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		if response["message"] != "Order not found" {
+			t.Errorf("Expected error message 'Order not found', got %s", response["message"])
+		}
+	})
+
+	t.Run("invalid status transitions", func(t *testing.T) {
+		tests := []struct {
+			from string
+			to   string
+		}{
+			{"delivered", "pending"},
+			{"shipped", "cancelled"},
+			{"pending", "shipped"},
+			{"cancelled", "confirmed"},
+		}
+
+		for _, tt := range tests {
+			repo := newTestOrderRepository(t,
+				models.Order{ID: 1, TransactionID: "txn-123", Status: tt.from},
+			)
+
+			router := setupGin()
+			router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, nil)))
+
+			reqBody, _ := json.Marshal(UpdateOrderStatusRequest{Status: tt.to})
+			req, _ := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("%s -> %s: expected status code %d, got %d", tt.from, tt.to, http.StatusConflict, w.Code)
+			}
+
+			if repo.stored(t, 1).Status != tt.from {
+				t.Errorf("%s -> %s: expected status to stay %s, got %s", tt.from, tt.to, tt.from, repo.stored(t, 1).Status)
+			}
+		}
+	})
+	t.Run("cancellation restocks products once", func(t *testing.T) {
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", Status: "confirmed", OrderItems: []models.OrderItem{
+				{ProductID: 1, Quantity: 2},
+				{ProductID: 2, Quantity: 5},
+			}},
+		)
+		productRepo := repo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Stock: 10},
+			models.Product{ID: 2, Name: "Product 2", Stock: 0},
+		)
+
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, productRepo)))
+
+		for i := 0; i < 2; i++ {
+			reqBody, _ := json.Marshal(UpdateOrderStatusRequest{Status: "cancelled"})
+			req, _ := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Attempt %d: expected status code %d, got %d", i+1, http.StatusOK, w.Code)
+			}
+		}
+
+		if repo.stored(t, 1).Status != "cancelled" {
+			t.Errorf("Expected status 'cancelled', got %s", repo.stored(t, 1).Status)
+		}
+
+		if productRepo.stored(t, 1).Stock != 12 {
+			t.Errorf("Expected product 1 stock to be 12, got %d", productRepo.stored(t, 1).Stock)
+		}
+
+		if productRepo.stored(t, 2).Stock != 5 {
+			t.Errorf("Expected product 2 stock to be 5, got %d", productRepo.stored(t, 2).Stock)
+		}
+	})
+
+	t.Run("other transitions do not restock", func(t *testing.T) {
+		repo := newTestOrderRepository(t,
+			models.Order{ID: 1, TransactionID: "txn-123", Status: "pending", OrderItems: []models.OrderItem{
+				{ProductID: 1, Quantity: 2},
+			}},
+		)
+		productRepo := repo.withProducts(t,
+			models.Product{ID: 1, Name: "Product 1", Stock: 10},
+		)
+
+		router := setupGin()
+		router.PUT("/orders/:id/status", UpdateOrderStatus(newOrderService(repo, productRepo)))
+
+		reqBody, _ := json.Marshal(UpdateOrderStatusRequest{Status: "confirmed"})
+		req, _ := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if productRepo.stored(t, 1).Stock != 10 {
+			t.Errorf("Expected product 1 stock to stay 10, got %d", productRepo.stored(t, 1).Stock)
 		}
 	})
 }
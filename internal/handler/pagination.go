@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gorepositorytest/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type pageResponse[T any] struct {
+	Items    []T    `json:"items"`
+	Total    int64  `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+	Next     string `json:"next,omitempty"`
+	Prev     string `json:"prev,omitempty"`
+}
+
+func parsePagination(c *gin.Context) (repository.Pagination, error) {
+	p := repository.Pagination{Page: 1, PageSize: repository.DefaultPageSize}
+
+	if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 || page > repository.MaxPage {
+			return p, errors.New("page must be between 1 and " + strconv.Itoa(repository.MaxPage))
+		}
+		p.Page = page
+	}
+
+	if value := c.Query("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 || size > repository.MaxPageSize {
+			return p, errors.New("page_size must be between 1 and " + strconv.Itoa(repository.MaxPageSize))
+		}
+		p.PageSize = size
+	}
+
+	return p, nil
+}
+
+// parseSort reads the sort query parameter, e.g. "price" for ascending or
+// "-price" for descending order.
+func parseSort(c *gin.Context, valid func(string) bool) (string, bool, error) {
+	value := c.Query("sort")
+	if value == "" {
+		return "", false, nil
+	}
+	field := strings.TrimPrefix(value, "-")
+	if !valid(field) {
+		return "", false, errors.New("cannot sort by " + field)
+	}
+	return field, strings.HasPrefix(value, "-"), nil
+}
+
+func newPageResponse[T any](c *gin.Context, items []T, total int64, p repository.Pagination) pageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	resp := pageResponse[T]{
+		Items:    items,
+		Total:    total,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+	}
+	if int64(p.Page*p.PageSize) < total {
+		resp.Next = pageLink(c, p.Page+1)
+	}
+	if p.Page > 1 {
+		resp.Prev = pageLink(c, p.Page-1)
+	}
+	return resp
+}
+
+// pageLink returns the current request URL pointing at another page.
+func pageLink(c *gin.Context, page int) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + query.Encode()
+}
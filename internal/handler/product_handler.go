@@ -1,61 +1,203 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"gorepositorytest/internal/apperror"
 	"gorepositorytest/internal/models"
 	"gorepositorytest/internal/repository"
+	"gorepositorytest/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetAllProducts(repo repository.ProductRepository) gin.HandlerFunc {
+func parseProductListOptions(c *gin.Context) (repository.ProductListOptions, error) {
+	var opts repository.ProductListOptions
+
+	pagination, err := parsePagination(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.Pagination = pagination
+
+	opts.SortBy, opts.SortDesc, err = parseSort(c, repository.IsValidProductSort)
+	if err != nil {
+		return opts, err
+	}
+
+	if value := c.Query("min_price"); value != "" {
+		price, err := models.ParseMoney(value)
+		if err != nil {
+			return opts, errors.New("invalid min_price")
+		}
+		opts.MinPrice = &price
+	}
+
+	if value := c.Query("max_price"); value != "" {
+		price, err := models.ParseMoney(value)
+		if err != nil {
+			return opts, errors.New("invalid max_price")
+		}
+		opts.MaxPrice = &price
+	}
+
+	if value := c.Query("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, errors.New("invalid in_stock")
+		}
+		opts.InStock = inStock
+	}
+
+	opts.Search = c.Query("q")
+	return opts, nil
+}
+
+type ProductRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	Description string       `json:"description"`
+	Price       models.Money `json:"price" binding:"min=0"`
+	Currency    string       `json:"currency" binding:"omitempty,len=3"`
+	Stock       int          `json:"stock" binding:"min=0"`
+}
+
+// PatchProductRequest only changes the fields present in the request body.
+type PatchProductRequest struct {
+	Name        *string       `json:"name" binding:"omitempty,min=1"`
+	Description *string       `json:"description"`
+	Price       *models.Money `json:"price" binding:"omitempty,min=0"`
+	Currency    *string       `json:"currency" binding:"omitempty,len=3"`
+	Stock       *int          `json:"stock" binding:"omitempty,min=0"`
+}
+
+func parseProductID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(apperror.BadRequest("Invalid product ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func GetAllProducts(svc service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		products, err := repo.GetAll()
+		opts, err := parseProductListOptions(c)
+		if err != nil {
+			c.Error(apperror.BadRequest(err.Error()))
+			return
+		}
+
+		products, total, err := svc.List(c.Request.Context(), opts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
-		c.JSON(http.StatusOK, products)
+		c.JSON(http.StatusOK, newPageResponse(c, products, total, opts.Pagination))
 	}
 }
 
-func AddProduct(repo repository.ProductRepository) gin.HandlerFunc {
+func GetProductByID(svc service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var product models.Product
-		if err := c.ShouldBindJSON(&product); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		id, ok := parseProductID(c)
+		if !ok {
 			return
 		}
-		if err := repo.Create(&product); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		product, err := svc.Get(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		c.JSON(http.StatusCreated, product)
+		c.JSON(http.StatusOK, product)
 	}
 }
 
-func DeleteProduct(repo repository.ProductRepository) gin.HandlerFunc {
+func UpdateProduct(svc service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		idParam := c.Param("id")
-		id, err := strconv.ParseUint(idParam, 10, 32)
+		id, ok := parseProductID(c)
+		if !ok {
+			return
+		}
+
+		var req ProductRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		product, err := svc.Replace(c.Request.Context(), id, service.ProductInput(req))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, product)
+	}
+}
+
+func PatchProduct(svc service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseProductID(c)
+		if !ok {
+			return
+		}
+
+		var req PatchProductRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		product, err := svc.Patch(c.Request.Context(), id, service.ProductPatch(req))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, product)
+	}
+}
+
+func AddProduct(svc service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProductRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
-		_, err = repo.GetByID(uint(id))
+		product, err := svc.Create(c.Request.Context(), service.ProductInput(req))
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			c.Error(err)
 			return
 		}
+		c.JSON(http.StatusCreated, product)
+	}
+}
 
-		if err := repo.Delete(uint(id)); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func DeleteProduct(svc service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseProductID(c)
+		if !ok {
 			return
 		}
 
+		if err := svc.Archive(c.Request.Context(), id); err != nil {
+			c.Error(err)
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 	}
 }
+
+func RestoreProduct(svc service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseProductID(c)
+		if !ok {
+			return
+		}
+
+		if err := svc.Restore(c.Request.Context(), id); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product restored successfully"})
+	}
+}
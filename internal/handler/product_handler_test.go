@@ -2,100 +2,148 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/middleware"
 	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+	"gorepositorytest/internal/service"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// Mock repository for testing
-type mockProductRepository struct {
-	products     []models.Product
+// testProductRepository is the in-memory repository with switches that make
+// its calls fail like a broken database would.
+type testProductRepository struct {
+	repository.ProductRepository
+	store        *repository.MemoryStore
 	shouldError  bool
 	errorMsg     string
 	getByIDError bool
 	createError  bool
 	deleteError  bool
+	updateError  bool
+	lastListOpts repository.ProductListOptions
 }
 
-func (m *mockProductRepository) GetAll() ([]models.Product, error) {
-	if m.shouldError {
-		return nil, errors.New(m.errorMsg)
+func newTestProductRepository(t *testing.T, products ...models.Product) *testProductRepository {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	repo := &testProductRepository{ProductRepository: repository.NewMemoryProductRepository(store), store: store}
+	for _, product := range products {
+		if err := repo.ProductRepository.Create(context.Background(), &product); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
 	}
-	return m.products, nil
+	return repo
 }
 
-func (m *mockProductRepository) GetByID(id uint) (*models.Product, error) {
-	if m.getByIDError {
-		return nil, errors.New(m.errorMsg)
+// stored reads product id straight from the store.
+func (r *testProductRepository) stored(t *testing.T, id uint) models.Product {
+	t.Helper()
+	product, err := r.ProductRepository.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to read product %d: %v", id, err)
 	}
-	for _, product := range m.products {
-		if product.ID == id {
-			return &product, nil
-		}
+	return *product
+}
+
+func (r *testProductRepository) count(t *testing.T) int {
+	t.Helper()
+	products, err := r.ProductRepository.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read products: %v", err)
 	}
-	return nil, gorm.ErrRecordNotFound
+	return len(products)
 }
 
-func (m *mockProductRepository) Create(product *models.Product) error {
-	if m.createError {
-		return errors.New(m.errorMsg)
+func (r *testProductRepository) GetAll(ctx context.Context) ([]models.Product, error) {
+	if r.shouldError {
+		return nil, errors.New(r.errorMsg)
 	}
-	product.ID = uint(len(m.products) + 1)
-	product.CreatedAt = time.Now()
-	product.UpdatedAt = time.Now()
-	m.products = append(m.products, *product)
-	return nil
+	return r.ProductRepository.GetAll(ctx)
 }
 
-func (m *mockProductRepository) Update(product *models.Product) error {
-	if m.shouldError {
-		return errors.New(m.errorMsg)
+func (r *testProductRepository) List(ctx context.Context, opts repository.ProductListOptions) ([]models.Product, int64, error) {
+	r.lastListOpts = opts
+	if r.shouldError {
+		return nil, 0, errors.New(r.errorMsg)
 	}
-	for i, p := range m.products {
-		if p.ID == product.ID {
-			m.products[i] = *product
-			return nil
-		}
+	return r.ProductRepository.List(ctx, opts)
+}
+
+func (r *testProductRepository) GetByID(ctx context.Context, id uint) (*models.Product, error) {
+	if r.getByIDError {
+		return nil, errors.New(r.errorMsg)
 	}
-	return gorm.ErrRecordNotFound
+	return r.ProductRepository.GetByID(ctx, id)
 }
 
-func (m *mockProductRepository) Delete(id uint) error {
-	if m.deleteError {
-		return errors.New(m.errorMsg)
+func (r *testProductRepository) Create(ctx context.Context, product *models.Product) error {
+	if r.createError {
+		return errors.New(r.errorMsg)
 	}
-	for i, product := range m.products {
-		if product.ID == id {
-			m.products = append(m.products[:i], m.products[i+1:]...)
-			return nil
-		}
+	return r.ProductRepository.Create(ctx, product)
+}
+
+func (r *testProductRepository) Update(ctx context.Context, product *models.Product) error {
+	if r.shouldError || r.updateError {
+		return errors.New(r.errorMsg)
+	}
+	return r.ProductRepository.Update(ctx, product)
+}
+
+func (r *testProductRepository) Patch(ctx context.Context, id uint, changes repository.ProductChanges) error {
+	if r.shouldError || r.updateError {
+		return errors.New(r.errorMsg)
+	}
+	return r.ProductRepository.Patch(ctx, id, changes)
+}
+
+func (r *testProductRepository) Delete(ctx context.Context, id uint) error {
+	if r.deleteError {
+		return errors.New(r.errorMsg)
+	}
+	return r.ProductRepository.Delete(ctx, id)
+}
+
+func (r *testProductRepository) Restore(ctx context.Context, id uint) error {
+	if r.shouldError {
+		return errors.New(r.errorMsg)
 	}
-	return nil
+	return r.ProductRepository.Restore(ctx, id)
+}
+
+func (r *testProductRepository) DecrementStock(ctx context.Context, id uint, quantity int) error {
+	if r.shouldError {
+		return errors.New(r.errorMsg)
+	}
+	return r.ProductRepository.DecrementStock(ctx, id, quantity)
 }
 
 func setupGin() *gin.Engine {
 	gin.SetMode(gin.TestMode)
-	return gin.New()
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	return r
 }
 
 func TestGetAllProducts(t *testing.T) {
 	t.Run("successful get all products", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Description: "Description 1", Price: 10.99, Stock: 100},
-				{ID: 2, Name: "Product 2", Description: "Description 2", Price: 15.99, Stock: 50},
-			},
-		}
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Stock: 100},
+			models.Product{ID: 2, Name: "Product 2", Description: "Description 2", Price: 1599, Stock: 50},
+		)
 
 		router := setupGin()
-		router.GET("/products", GetAllProducts(mockRepo))
+		router.GET("/products", GetAllProducts(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("GET", "/products", nil)
 		w := httptest.NewRecorder()
@@ -105,28 +153,35 @@ func TestGetAllProducts(t *testing.T) {
 			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 		}
 
-		var products []models.Product
-		if err := json.Unmarshal(w.Body.Bytes(), &products); err != nil {
+		var page pageResponse[models.Product]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if len(products) != 2 {
-			t.Errorf("Expected 2 products, got %d", len(products))
+		if len(page.Items) != 2 {
+			t.Fatalf("Expected 2 products, got %d", len(page.Items))
+		}
+
+		if page.Items[0].Name != "Product 1" {
+			t.Errorf("Expected first product name to be 'Product 1', got %s", page.Items[0].Name)
+		}
+
+		if page.Total != 2 || page.Page != 1 || page.PageSize != repository.DefaultPageSize {
+			t.Errorf("Unexpected page metadata: %+v", page)
 		}
 
-		if products[0].Name != "Product 1" {
-			t.Errorf("Expected first product name to be 'Product 1', got %s", products[0].Name)
+		if page.Next != "" {
+			t.Errorf("Expected no next link, got %s", page.Next)
 		}
 	})
 
 	t.Run("repository error", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			shouldError: true,
-			errorMsg:    "database connection failed",
-		}
+		repo := newTestProductRepository(t)
+		repo.shouldError = true
+		repo.errorMsg = "database connection failed"
 
 		router := setupGin()
-		router.GET("/products", GetAllProducts(mockRepo))
+		router.GET("/products", GetAllProducts(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("GET", "/products", nil)
 		w := httptest.NewRecorder()
@@ -141,25 +196,347 @@ func TestGetAllProducts(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "database connection failed" {
-			t.Errorf("Expected error message 'database connection failed', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
+		}
+	})
+}
+
+func TestGetAllProducts_QueryOptions(t *testing.T) {
+	t.Run("filters, sort and pagination are passed to the repository", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+			models.Product{ID: 2, Name: "Product 2", Price: 1599, Stock: 50},
+			models.Product{ID: 3, Name: "Product 3", Price: 2599, Stock: 10},
+		)
+
+		router := setupGin()
+		router.GET("/products", GetAllProducts(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("GET", "/products?page=2&page_size=1&sort=-price&min_price=10.50&max_price=30&in_stock=true&q=prod", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		opts := repo.lastListOpts
+		if opts.Page != 2 || opts.PageSize != 1 {
+			t.Errorf("Expected page 2 of size 1, got %+v", opts.Pagination)
+		}
+		if opts.SortBy != "price" || !opts.SortDesc {
+			t.Errorf("Expected descending price sort, got %s desc=%v", opts.SortBy, opts.SortDesc)
+		}
+		if opts.MinPrice == nil || *opts.MinPrice != 1050 {
+			t.Errorf("Expected min price 1050, got %v", opts.MinPrice)
+		}
+		if opts.MaxPrice == nil || *opts.MaxPrice != 3000 {
+			t.Errorf("Expected max price 3000, got %v", opts.MaxPrice)
+		}
+		if !opts.InStock || opts.Search != "prod" {
+			t.Errorf("Expected in stock search for 'prod', got %+v", opts)
+		}
+
+		var page pageResponse[models.Product]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		if len(page.Items) != 1 || page.Items[0].ID != 2 {
+			t.Errorf("Expected product 2 on page 2, got %+v", page.Items)
+		}
+		if page.Total != 3 {
+			t.Errorf("Expected total 3, got %d", page.Total)
+		}
+		if !strings.Contains(page.Next, "page=3") || !strings.Contains(page.Next, "sort=-price") {
+			t.Errorf("Expected next link to page 3 keeping the query, got %s", page.Next)
+		}
+		if !strings.Contains(page.Prev, "page=1") {
+			t.Errorf("Expected prev link to page 1, got %s", page.Prev)
+		}
+	})
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{"page=0", "page=abc", "page=4611686018427387904", "page_size=1000", "sort=stock", "min_price=abc", "max_price=1.999", "in_stock=maybe"} {
+			router := setupGin()
+			router.GET("/products", GetAllProducts(service.NewProductService(newTestProductRepository(t))))
+
+			req, _ := http.NewRequest("GET", "/products?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+}
+
+func TestGetProductByID(t *testing.T) {
+	t.Run("successful get product", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Stock: 100},
+		)
+
+		router := setupGin()
+		router.GET("/products/:id", GetProductByID(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("GET", "/products/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var product models.Product
+		if err := json.Unmarshal(w.Body.Bytes(), &product); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		if product.Name != "Product 1" {
+			t.Errorf("Expected product name to be 'Product 1', got %s", product.Name)
+		}
+	})
+
+	t.Run("invalid product id", func(t *testing.T) {
+		router := setupGin()
+		router.GET("/products/:id", GetProductByID(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("GET", "/products/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		router := setupGin()
+		router.GET("/products/:id", GetProductByID(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("GET", "/products/999", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+
+		var response map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		if response["message"] != "Product not found" {
+			t.Errorf("Expected error message 'Product not found', got %s", response["message"])
+		}
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		repo := newTestProductRepository(t)
+		repo.getByIDError = true
+		repo.errorMsg = "database connection failed"
+
+		router := setupGin()
+		router.GET("/products/:id", GetProductByID(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("GET", "/products/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
+
+func TestUpdateProduct(t *testing.T) {
+	t.Run("successful update product", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Currency: "USD", Stock: 100},
+		)
+
+		router := setupGin()
+		router.PUT("/products/:id", UpdateProduct(service.NewProductService(repo)))
+
+		body := `{"name": "Renamed", "description": "New description", "price": 12.50, "currency": "EUR", "stock": 7}`
+		req, _ := http.NewRequest("PUT", "/products/1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		updated := repo.stored(t, 1)
+		if updated.Name != "Renamed" || updated.Description != "New description" || updated.Price != 1250 || updated.Currency != "EUR" || updated.Stock != 7 {
+			t.Errorf("Unexpected product after update: %+v", updated)
+		}
+	})
+
+	t.Run("missing currency falls back to default", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Currency: "EUR", Stock: 100},
+		)
+
+		router := setupGin()
+		router.PUT("/products/:id", UpdateProduct(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("PUT", "/products/1", bytes.NewBufferString(`{"name": "Product 1", "price": 10.99, "stock": 100}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if repo.stored(t, 1).Currency != models.DefaultCurrency {
+			t.Errorf("Expected currency %s, got %s", models.DefaultCurrency, repo.stored(t, 1).Currency)
+		}
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		bodies := []string{
+			`{"price": 10, "stock": 1}`,
+			`{"name": "Product", "price": -1, "stock": 1}`,
+			`{"name": "Product", "price": 1, "stock": -5}`,
+			`{"name": "Product", "price": 1, "stock": 1, "currency": "DOLLAR"}`,
+			`{"name": "Product", "price": 1, "stock": 1, "currency": "usd"}`,
+			`{"name": "Product", "price": 1, "stock": 1, "currency": "JPY"}`,
+		}
+
+		for _, body := range bodies {
+			repo := newTestProductRepository(t,
+				models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+			)
+
+			router := setupGin()
+			router.PUT("/products/:id", UpdateProduct(service.NewProductService(repo)))
+
+			req, _ := http.NewRequest("PUT", "/products/1", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusUnprocessableEntity, w.Code)
+			}
+
+			if repo.stored(t, 1).Name != "Product 1" {
+				t.Errorf("%s: expected product to be unchanged", body)
+			}
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		router := setupGin()
+		router.PUT("/products/:id", UpdateProduct(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("PUT", "/products/999", bytes.NewBufferString(`{"name": "Product", "price": 1, "stock": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("update repository error", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+		)
+		repo.updateError = true
+		repo.errorMsg = "database error"
+
+		router := setupGin()
+		router.PUT("/products/:id", UpdateProduct(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("PUT", "/products/1", bytes.NewBufferString(`{"name": "Product", "price": 1, "stock": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
+
+func TestPatchProduct(t *testing.T) {
+	t.Run("only provided fields change", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Currency: "USD", Stock: 100},
+		)
+
+		router := setupGin()
+		router.PATCH("/products/:id", PatchProduct(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("PATCH", "/products/1", bytes.NewBufferString(`{"price": 8.99, "stock": 0}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		patched := repo.stored(t, 1)
+		if patched.Price != 899 || patched.Stock != 0 {
+			t.Errorf("Expected price 899 and stock 0, got %d and %d", patched.Price, patched.Stock)
+		}
+		if patched.Name != "Product 1" || patched.Description != "Description 1" || patched.Currency != "USD" {
+			t.Errorf("Expected other fields to be unchanged, got %+v", patched)
+		}
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		for _, body := range []string{`{"name": ""}`, `{"price": -0.01}`, `{"stock": -1}`} {
+			repo := newTestProductRepository(t,
+				models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+			)
+
+			router := setupGin()
+			router.PATCH("/products/:id", PatchProduct(service.NewProductService(repo)))
+
+			req, _ := http.NewRequest("PATCH", "/products/1", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("%s: expected status code %d, got %d", body, http.StatusUnprocessableEntity, w.Code)
+			}
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		router := setupGin()
+		router.PATCH("/products/:id", PatchProduct(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("PATCH", "/products/999", bytes.NewBufferString(`{"stock": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
 		}
 	})
 }
 
 func TestAddProduct(t *testing.T) {
 	t.Run("successful add product", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{},
-		}
+		repo := newTestProductRepository(t)
 
 		router := setupGin()
-		router.POST("/products", AddProduct(mockRepo))
+		router.POST("/products", AddProduct(service.NewProductService(repo)))
 
 		product := models.Product{
 			Name:        "New Product",
 			Description: "New Description",
-			Price:       25.99,
+			Price:       2599,
 			Stock:       75,
 		}
 
@@ -188,14 +565,12 @@ func TestAddProduct(t *testing.T) {
 	})
 
 	t.Run("invalid request body", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{},
-		}
+		repo := newTestProductRepository(t)
 
 		router := setupGin()
-		router.POST("/products", AddProduct(mockRepo))
+		router.POST("/products", AddProduct(service.NewProductService(repo)))
 
-		invalidJSON := `{"name": "Product", "price": "invalid_price"}`
+		invalidJSON := `{"name": "Product", "price": }`
 		req, _ := http.NewRequest("POST", "/products", bytes.NewBuffer([]byte(invalidJSON)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
@@ -210,24 +585,103 @@ func TestAddProduct(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Invalid request body" {
-			t.Errorf("Expected error message 'Invalid request body', got %s", response["error"])
+		if response["message"] != "Invalid request body" {
+			t.Errorf("Expected error message 'Invalid request body', got %s", response["message"])
 		}
 	})
 
-	t.Run("repository create error", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			createError: true,
-			errorMsg:    "failed to create product",
+	t.Run("values of the wrong type", func(t *testing.T) {
+		tests := []struct {
+			body     string
+			expected apperror.FieldError
+		}{
+			{`{"name": "Product", "price": 10.999}`, apperror.FieldError{Field: "price", Rule: "amount", Message: "price must have at most 2 decimal places"}},
+			{`{"name": "Product", "price": "abc"}`, apperror.FieldError{Field: "price", Rule: "amount", Message: "price must be a decimal number"}},
+			{`{"name": "Product", "price": 184467440737095517}`, apperror.FieldError{Field: "price", Rule: "amount", Message: "price is too large"}},
+			{`{"name": "Product", "price": 1, "stock": "many"}`, apperror.FieldError{Field: "stock", Rule: "type", Message: "stock must be a whole number"}},
+		}
+
+		for _, tt := range tests {
+			repo := newTestProductRepository(t)
+
+			router := setupGin()
+			router.POST("/products", AddProduct(service.NewProductService(repo)))
+
+			req, _ := http.NewRequest("POST", "/products", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("%s: expected status code %d, got %d", tt.body, http.StatusUnprocessableEntity, w.Code)
+			}
+
+			var response struct {
+				Details []apperror.FieldError `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Errorf("Failed to unmarshal response: %v", err)
+			}
+
+			if !reflect.DeepEqual(response.Details, []apperror.FieldError{tt.expected}) {
+				t.Errorf("%s: expected fields %+v, got %+v", tt.body, tt.expected, response.Details)
+			}
+		}
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		repo := newTestProductRepository(t)
+
+		router := setupGin()
+		router.POST("/products", AddProduct(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("POST", "/products", bytes.NewBufferString(`{"price": -1, "stock": 1, "currency": "EU"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		var response struct {
+			Message string                `json:"message"`
+			Details []apperror.FieldError `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("Failed to unmarshal response: %v", err)
+		}
+
+		if response.Message != "Validation failed" {
+			t.Errorf("Expected error message 'Validation failed', got %s", response.Message)
+		}
+
+		expected := []apperror.FieldError{
+			{Field: "name", Rule: "required", Message: "name is required"},
+			{Field: "price", Rule: "min", Message: "price must be at least 0"},
+			{Field: "currency", Rule: "len", Message: "currency must be exactly 3 characters long"},
 		}
+		if !reflect.DeepEqual(response.Details, expected) {
+			t.Errorf("Expected fields %+v, got %+v", expected, response.Details)
+		}
+
+		if repo.count(t) != 0 {
+			t.Errorf("Expected no product to be created, got %d", repo.count(t))
+		}
+	})
+
+	t.Run("repository create error", func(t *testing.T) {
+		repo := newTestProductRepository(t)
+		repo.createError = true
+		repo.errorMsg = "failed to create product"
 
 		router := setupGin()
-		router.POST("/products", AddProduct(mockRepo))
+		router.POST("/products", AddProduct(service.NewProductService(repo)))
 
 		product := models.Product{
 			Name:        "New Product",
 			Description: "New Description",
-			Price:       25.99,
+			Price:       2599,
 			Stock:       75,
 		}
 
@@ -246,22 +700,20 @@ func TestAddProduct(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "failed to create product" {
-			t.Errorf("Expected error message 'failed to create product', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
 		}
 	})
 }
 
 func TestDeleteProduct(t *testing.T) {
 	t.Run("successful delete product", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Description: "Description 1", Price: 10.99, Stock: 100},
-			},
-		}
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Stock: 100},
+		)
 
 		router := setupGin()
-		router.DELETE("/products/:id", DeleteProduct(mockRepo))
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("DELETE", "/products/1", nil)
 		w := httptest.NewRecorder()
@@ -282,12 +734,10 @@ func TestDeleteProduct(t *testing.T) {
 	})
 
 	t.Run("invalid product id", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{},
-		}
+		repo := newTestProductRepository(t)
 
 		router := setupGin()
-		router.DELETE("/products/:id", DeleteProduct(mockRepo))
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("DELETE", "/products/invalid", nil)
 		w := httptest.NewRecorder()
@@ -302,18 +752,16 @@ func TestDeleteProduct(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Invalid product ID" {
-			t.Errorf("Expected error message 'Invalid product ID', got %s", response["error"])
+		if response["message"] != "Invalid product ID" {
+			t.Errorf("Expected error message 'Invalid product ID', got %s", response["message"])
 		}
 	})
 
 	t.Run("product not found", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{},
-		}
+		repo := newTestProductRepository(t)
 
 		router := setupGin()
-		router.DELETE("/products/:id", DeleteProduct(mockRepo))
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("DELETE", "/products/999", nil)
 		w := httptest.NewRecorder()
@@ -328,22 +776,20 @@ func TestDeleteProduct(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "Product not found" {
-			t.Errorf("Expected error message 'Product not found', got %s", response["error"])
+		if response["message"] != "Product not found" {
+			t.Errorf("Expected error message 'Product not found', got %s", response["message"])
 		}
 	})
 
 	t.Run("delete repository error", func(t *testing.T) {
-		mockRepo := &mockProductRepository{
-			products: []models.Product{
-				{ID: 1, Name: "Product 1", Description: "Description 1", Price: 10.99, Stock: 100},
-			},
-			deleteError: true,
-			errorMsg:    "failed to delete product",
-		}
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Description: "Description 1", Price: 1099, Stock: 100},
+		)
+		repo.deleteError = true
+		repo.errorMsg = "failed to delete product"
 
 		router := setupGin()
-		router.DELETE("/products/:id", DeleteProduct(mockRepo))
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
 
 		req, _ := http.NewRequest("DELETE", "/products/1", nil)
 		w := httptest.NewRecorder()
@@ -358,8 +804,87 @@ func TestDeleteProduct(t *testing.T) {
 			t.Errorf("Failed to unmarshal response: %v", err)
 		}
 
-		if response["error"] != "failed to delete product" {
-			t.Errorf("Expected error message 'failed to delete product', got %s", response["error"])
+		if response["message"] != "Internal server error" {
+			t.Errorf("Expected error message 'Internal server error', got %s", response["message"])
+		}
+	})
+
+	t.Run("lookup error is not reported as missing", func(t *testing.T) {
+		repo := newTestProductRepository(t)
+		repo.getByIDError = true
+		repo.errorMsg = "connection refused"
+
+		router := setupGin()
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
+
+		req, _ := http.NewRequest("DELETE", "/products/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+
+		if strings.Contains(w.Body.String(), "connection refused") {
+			t.Errorf("Expected database error to stay hidden, got %s", w.Body.String())
+		}
+	})
+}
+
+func TestRestoreProduct(t *testing.T) {
+	t.Run("deleted product can be restored", func(t *testing.T) {
+		repo := newTestProductRepository(t,
+			models.Product{ID: 1, Name: "Product 1", Price: 1099, Stock: 100},
+		)
+
+		router := setupGin()
+		router.DELETE("/products/:id", DeleteProduct(service.NewProductService(repo)))
+		router.POST("/products/:id/restore", RestoreProduct(service.NewProductService(repo)))
+		router.GET("/products/:id", GetProductByID(service.NewProductService(repo)))
+
+		for _, step := range []struct {
+			method       string
+			path         string
+			expectedCode int
+		}{
+			{"DELETE", "/products/1", http.StatusOK},
+			{"GET", "/products/1", http.StatusNotFound},
+			{"POST", "/products/1/restore", http.StatusOK},
+			{"GET", "/products/1", http.StatusOK},
+		} {
+			req, _ := http.NewRequest(step.method, step.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != step.expectedCode {
+				t.Errorf("%s %s: expected status code %d, got %d", step.method, step.path, step.expectedCode, w.Code)
+			}
+		}
+	})
+
+	t.Run("product not archived", func(t *testing.T) {
+		router := setupGin()
+		router.POST("/products/:id/restore", RestoreProduct(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("POST", "/products/1/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("invalid product id", func(t *testing.T) {
+		router := setupGin()
+		router.POST("/products/:id/restore", RestoreProduct(service.NewProductService(newTestProductRepository(t))))
+
+		req, _ := http.NewRequest("POST", "/products/abc/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 		}
 	})
 }
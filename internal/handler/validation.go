@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by their JSON names so clients can map errors back to
+	// the request body.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// bindJSON decodes and validates the request body into obj. Malformed JSON
+// is reported as a bad request. Failed validation, and values of the wrong
+// type such as an amount with too many decimal places, are reported as a
+// validation error listing the failing fields. It reports whether the
+// handler may continue.
+func bindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		c.Error(apperror.Validation("Validation failed", fieldErrors(validationErrors)))
+		return false
+	}
+
+	var amountErr *models.AmountError
+	if errors.As(err, &amountErr) {
+		if field := amountField(obj); field != "" {
+			c.Error(apperror.Validation("Validation failed", []apperror.FieldError{
+				{Field: field, Rule: "amount", Message: field + " " + amountErr.Reason},
+			}))
+			return false
+		}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		field := fieldPath(typeErr.Field)
+		c.Error(apperror.Validation("Validation failed", []apperror.FieldError{
+			{Field: field, Rule: "type", Message: typeErrorMessage(field, typeErr.Type)},
+		}))
+		return false
+	}
+
+	c.Error(apperror.BadRequest("Invalid request body").WithCause(err))
+	return false
+}
+
+// amountField returns the JSON name of the only Money field of the request
+// struct obj, or "" if there is none or several. encoding/json does not say
+// which field an UnmarshalJSON error came from.
+func amountField(obj any) string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+
+	var found string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft != reflect.TypeFor[models.Money]() {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+	}
+	return found
+}
+
+// fieldPath turns encoding/json's "order_items.0.quantity" into
+// "order_items[0].quantity", the form validation errors use.
+func fieldPath(jsonPath string) string {
+	var path strings.Builder
+	for i, part := range strings.Split(jsonPath, ".") {
+		switch {
+		case part != "" && strings.Trim(part, "0123456789") == "":
+			path.WriteString("[" + part + "]")
+		case i > 0:
+			path.WriteString("." + part)
+		default:
+			path.WriteString(part)
+		}
+	}
+	return path.String()
+}
+
+func typeErrorMessage(field string, t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field + " must be a whole number"
+	case reflect.Float32, reflect.Float64:
+		return field + " must be a number"
+	case reflect.String:
+		return field + " must be a string"
+	case reflect.Bool:
+		return field + " must be true or false"
+	case reflect.Slice, reflect.Array:
+		return field + " must be a list"
+	}
+	return field + " must be an object"
+}
+
+func fieldErrors(validationErrors validator.ValidationErrors) []apperror.FieldError {
+	fields := make([]apperror.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		// Drop the struct name, e.g. "CreateOrderRequest.order_items[0].quantity".
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		fields = append(fields, apperror.FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(field, fe),
+		})
+	}
+	return fields
+}
+
+func fieldErrorMessage(field string, fe validator.FieldError) string {
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Array
+	isText := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "min", "gte":
+		if isList {
+			return fmt.Sprintf("%s must contain at least %s items", field, fe.Param())
+		}
+		if isText {
+			return fmt.Sprintf("%s must be at least %s characters long", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max", "lte":
+		if isList {
+			return fmt.Sprintf("%s must contain at most %s items", field, fe.Param())
+		}
+		if isText {
+			return fmt.Sprintf("%s must be at most %s characters long", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters long", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+}
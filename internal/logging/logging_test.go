@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lines decodes the JSON lines written to buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected a JSON line, got %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNew(t *testing.T) {
+	t.Run("adds the request ID from the context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, "info").With("component", "test")
+
+		logger.InfoContext(WithRequestID(context.Background(), "req-123"), "hello", "user", "user-1")
+		logger.Info("no request")
+
+		records := lines(t, &buf)
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 lines, got %d", len(records))
+		}
+		if records[0]["msg"] != "hello" || records[0]["request_id"] != "req-123" || records[0]["component"] != "test" {
+			t.Errorf("Expected the request ID and attributes, got %v", records[0])
+		}
+		if _, ok := records[1]["request_id"]; ok {
+			t.Errorf("Expected no request ID, got %v", records[1])
+		}
+	})
+
+	t.Run("drops records below the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, "warn")
+
+		logger.Info("ignored")
+		logger.Warn("kept")
+
+		records := lines(t, &buf)
+		if len(records) != 1 || records[0]["level"] != "WARN" {
+			t.Errorf("Expected only the warning, got %v", records)
+		}
+	})
+}
+
+func TestGormLogger_Trace(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-123")
+	sql := func() (string, int64) { return `SELECT * FROM "products" WHERE id = $1`, 0 }
+
+	tests := []struct {
+		name    string
+		level   string
+		begin   time.Time
+		err     error
+		message string
+	}{
+		{"failed query", "info", time.Now(), errors.New("connection reset"), "query failed"},
+		{"slow query", "info", time.Now().Add(-time.Second), nil, "slow query"},
+		{"query at debug level", "debug", time.Now(), nil, "query"},
+		{"query at info level", "info", time.Now(), nil, ""},
+		{"missing row", "info", time.Now(), gorm.ErrRecordNotFound, ""},
+		{"cancelled query", "info", time.Now(), context.Canceled, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			NewGormLogger(New(&buf, tt.level)).Trace(ctx, tt.begin, sql, tt.err)
+
+			records := lines(t, &buf)
+			if tt.message == "" {
+				if len(records) != 0 {
+					t.Errorf("Expected nothing to be logged, got %v", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 line, got %d", len(records))
+			}
+			record := records[0]
+			if record["msg"] != tt.message || record["request_id"] != "req-123" || record["sql"] == nil {
+				t.Errorf("Expected %q with the request ID and SQL, got %v", tt.message, record)
+			}
+			if tt.err != nil && record["error"] != tt.err.Error() {
+				t.Errorf("Expected error %q, got %v", tt.err, record["error"])
+			}
+		})
+	}
+}
+
+func TestGormLogger_ParamsFilter(t *testing.T) {
+	sql, params := NewGormLogger(New(&bytes.Buffer{}, "info")).ParamsFilter(context.Background(), "SELECT * FROM orders WHERE customer_id = $1", "user-1")
+
+	if sql != "SELECT * FROM orders WHERE customer_id = $1" || params != nil {
+		t.Errorf("Expected the SQL without values, got %q %v", sql, params)
+	}
+}
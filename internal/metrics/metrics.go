@@ -0,0 +1,125 @@
+// Package metrics exposes the server's Prometheus metrics: HTTP traffic by
+// route, database pool statistics and order activity.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorepositorytest/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that matched no route, so unknown paths
+// cannot create new series.
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels requests with a method outside standardMethods. The
+// method comes from the caller, before authentication, so it must not
+// create new series either.
+const otherMethod = "other"
+
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	ordersCreated    *prometheus.CounterVec
+	orderTransitions *prometheus.CounterVec
+	stockOuts        prometheus.Counter
+}
+
+// New registers the server's metrics, along with the Go runtime and process
+// metrics, on a registry of their own.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests handled, by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by method, route template and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		ordersCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_created_total",
+			Help: "Orders placed, by currency.",
+		}, []string{"currency"}),
+		orderTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "order_status_transitions_total",
+			Help: "Order status changes, by previous and new status.",
+		}, []string{"from", "to"}),
+		stockOuts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "order_stock_out_rejections_total",
+			Help: "Orders rejected because a product did not have enough stock.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.ordersCreated,
+		m.orderTransitions,
+		m.stockOuts,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with
+// name.
+func (m *Metrics) RegisterDB(name string, db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a handled request. route is the route template,
+// such as /products/:id, or "" when no route matched. Methods outside the
+// standard set are counted as "other".
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if !standardMethods[method] {
+		method = otherMethod
+	}
+	if route == "" {
+		route = unmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.requestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+func (m *Metrics) OrderPlaced(order *models.Order) {
+	m.ordersCreated.WithLabelValues(order.Currency).Inc()
+}
+
+func (m *Metrics) OrderStatusChanged(from, to string) {
+	m.orderTransitions.WithLabelValues(from, to).Inc()
+}
+
+func (m *Metrics) StockOutRejected() {
+	m.stockOuts.Inc()
+}
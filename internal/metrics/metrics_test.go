@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorepositorytest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("requests by route template and status", func(t *testing.T) {
+		m := New()
+		m.ObserveRequest("GET", "/products/:id", 200, 20*time.Millisecond)
+		m.ObserveRequest("GET", "/products/:id", 200, 30*time.Millisecond)
+		m.ObserveRequest("GET", "", 404, time.Millisecond)
+
+		body := scrape(t, m)
+
+		for _, want := range []string{
+			`http_requests_total{method="GET",route="/products/:id",status="200"} 2`,
+			`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+			`http_request_duration_seconds_count{method="GET",route="/products/:id",status="200"} 2`,
+			`go_goroutines`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %s in:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("non-standard methods share one series", func(t *testing.T) {
+		m := New()
+		m.ObserveRequest("FOO1", "", 404, time.Millisecond)
+		m.ObserveRequest("FOO2", "", 404, time.Millisecond)
+		m.ObserveRequest("BAR", "", 404, time.Millisecond)
+
+		body := scrape(t, m)
+
+		if want := `http_requests_total{method="other",route="unmatched",status="404"} 3`; !strings.Contains(body, want) {
+			t.Errorf("Expected %s in:\n%s", want, body)
+		}
+		for _, method := range []string{"FOO1", "FOO2", "BAR"} {
+			if strings.Contains(body, `method="`+method+`"`) {
+				t.Errorf("Expected no series for method %s in:\n%s", method, body)
+			}
+		}
+	})
+
+	t.Run("order activity", func(t *testing.T) {
+		m := New()
+		m.OrderPlaced(&models.Order{Currency: "EUR"})
+		m.OrderStatusChanged(models.OrderStatusPending, models.OrderStatusCancelled)
+		m.StockOutRejected()
+		m.StockOutRejected()
+
+		body := scrape(t, m)
+
+		for _, want := range []string{
+			`orders_created_total{currency="EUR"} 1`,
+			`order_status_transitions_total{from="pending",to="cancelled"} 1`,
+			`order_stock_out_rejections_total 2`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %s in:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("database pool", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(25)
+
+		m := New()
+		if err := m.RegisterDB("postgres", db); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if body := scrape(t, m); !strings.Contains(body, `go_sql_max_open_connections{db_name="postgres"} 25`) {
+			t.Errorf("Expected the pool statistics in:\n%s", body)
+		}
+	})
+}
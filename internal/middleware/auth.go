@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const claimsKey = "auth.claims"
+
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
+type AuthConfig struct {
+	Algorithm string // HS256 or RS256
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+	Issuer    string
+	Audience  string
+	ClockSkew time.Duration
+}
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewAuthConfig builds the JWT settings. key is the HMAC secret for HS256 or
+// the PEM encoded RSA public key for RS256.
+func NewAuthConfig(algorithm string, key []byte, issuer, audience string, clockSkew time.Duration) (AuthConfig, error) {
+	cfg := AuthConfig{
+		Algorithm: algorithm,
+		Issuer:    issuer,
+		Audience:  audience,
+		ClockSkew: clockSkew,
+	}
+
+	switch algorithm {
+	case "HS256":
+		if len(key) == 0 {
+			return AuthConfig{}, errors.New("HS256 requires a secret")
+		}
+		cfg.Secret = key
+	case "RS256":
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(key)
+		if err != nil {
+			return AuthConfig{}, fmt.Errorf("invalid RSA public key: %w", err)
+		}
+		cfg.PublicKey = publicKey
+	default:
+		return AuthConfig{}, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	return cfg, nil
+}
+
+func (cfg AuthConfig) parseToken(tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.ClockSkew),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		if cfg.Algorithm == "RS256" {
+			return cfg.PublicKey, nil
+		}
+		return cfg.Secret, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token is missing the sub claim")
+	}
+
+	return claims, nil
+}
+
+func Authenticate(cfg AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The scheme is case-insensitive, as for every HTTP auth scheme.
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			abortWithError(c, apperror.Unauthorized("missing or malformed jwt"))
+			return
+		}
+
+		claims, err := cfg.parseToken(token)
+		if err != nil {
+			abortWithError(c, apperror.Unauthorized("invalid token").WithCause(err))
+			return
+		}
+
+		SetClaims(c, claims)
+		c.Next()
+	}
+}
+
+func SetClaims(c *gin.Context, claims *Claims) {
+	c.Set(claimsKey, claims)
+}
+
+// GetClaims returns the claims of the token accepted by Authenticate.
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+// RequireRole only lets the request through when the authenticated caller
+// holds at least one of roles. It must run after Authenticate.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortWithError(c, apperror.Unauthorized("missing or malformed jwt"))
+			return
+		}
+
+		if !claims.HasRole(roles...) {
+			abortWithError(c, apperror.Forbidden("insufficient permissions"))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gorepositorytest/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every error returned by the API.
+type ErrorResponse struct {
+	Code      apperror.Code `json:"code"`
+	Message   string        `json:"message"`
+	Details   any           `json:"details,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+var statusByCode = map[apperror.Code]int{
+	apperror.CodeBadRequest:        http.StatusBadRequest,
+	apperror.CodeValidation:        http.StatusUnprocessableEntity,
+	apperror.CodeIdempotencyReused: http.StatusUnprocessableEntity,
+	apperror.CodeNotFound:          http.StatusNotFound,
+	apperror.CodeConflict:          http.StatusConflict,
+	apperror.CodeInsufficientStock: http.StatusConflict,
+	apperror.CodeUnauthorized:      http.StatusUnauthorized,
+	apperror.CodeForbidden:         http.StatusForbidden,
+	apperror.CodeTimeout:           http.StatusGatewayTimeout,
+	apperror.CodeInternal:          http.StatusInternalServerError,
+}
+
+// StatusFor returns the HTTP status that err is reported with.
+func StatusFor(err error) int {
+	if status, ok := statusByCode[apperror.CodeOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorHandler turns the last error recorded with c.Error into an
+// ErrorResponse. Errors that are not *apperror.Error are logged and reported
+// as internal errors, or as timeouts once the request's deadline has passed,
+// so database details never reach the client.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		appErr := apperror.From(err)
+		// Drivers do not always wrap the context error when a query is
+		// cancelled, so check the request's own deadline as well.
+		if appErr.Code == apperror.CodeInternal && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+			appErr = apperror.Timeout(err)
+		}
+		if appErr.Code == apperror.CodeInternal || appErr.Code == apperror.CodeTimeout {
+			slog.ErrorContext(c.Request.Context(), "request failed", "error", err)
+		}
+
+		c.JSON(StatusFor(appErr), ErrorResponse{
+			Code:      appErr.Code,
+			Message:   appErr.Message,
+			Details:   appErr.Details,
+			RequestID: GetRequestID(c),
+		})
+	}
+}
+
+// abortWithError stops the chain and records err for ErrorHandler. The
+// status is set as well so the request is still rejected if ErrorHandler
+// is not installed.
+func abortWithError(c *gin.Context, err *apperror.Error) {
+	c.Status(StatusFor(err))
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	DefaultIdempotencyTTL    = 24 * time.Hour
+	DefaultIdempotencyLease  = time.Minute
+	maxIdempotencyKeyLen     = 255
+)
+
+type IdempotencyConfig struct {
+	Store repository.IdempotencyRepository
+	// TTL is how long a key is remembered; defaults to DefaultIdempotencyTTL.
+	TTL time.Duration
+	// Lease is how long a key stays reserved for a request that never
+	// finished, e.g. because the server crashed; defaults to
+	// DefaultIdempotencyLease. It should exceed the longest request.
+	Lease time.Duration
+}
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key header. Keys are scoped to the authenticated
+// caller, so it must run after Authenticate. Only successful responses are
+// stored; after a failure or a panic the key is released and the request can
+// be retried. Requests without the header are passed through unchanged.
+func Idempotency(cfg IdempotencyConfig) gin.HandlerFunc {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	lease := cfg.Lease
+	if lease <= 0 {
+		lease = DefaultIdempotencyLease
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			abortWithError(c, apperror.BadRequest("Idempotency-Key must be at most 255 characters long"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, apperror.BadRequest("Invalid request body").WithCause(err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		if err := cfg.Store.DeleteExpired(c.Request.Context(), now.Add(-ttl), now.Add(-lease)); err != nil {
+			abortWithError(c, apperror.Internal(err))
+			return
+		}
+
+		var scope string
+		if claims, ok := GetClaims(c); ok {
+			scope = claims.Subject
+		}
+		record := &models.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hashRequest(c.Request, body),
+		}
+
+		existing, err := cfg.Store.Reserve(c.Request.Context(), record)
+		if err != nil {
+			abortWithError(c, apperror.Internal(err))
+			return
+		}
+		if existing != nil {
+			replay(c, existing, record.RequestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		defer func() {
+			// The request context may already be cancelled; the outcome must
+			// be recorded regardless or the key stays reserved until its
+			// lease runs out.
+			ctx := context.WithoutCancel(c.Request.Context())
+
+			// A panicking handler is answered by Recovery further up, which
+			// still needs the panic; the key is released so a retry can run.
+			if recovered := recover(); recovered != nil {
+				if err := cfg.Store.Release(ctx, record); err != nil {
+					slog.ErrorContext(ctx, "releasing idempotency key failed", "error", err)
+				}
+				panic(recovered)
+			}
+
+			status := recorder.Status()
+			if len(c.Errors) > 0 || status < 200 || status >= 300 {
+				// The handler's own error is the one to report; adding this
+				// one to c.Errors would replace it.
+				if err := cfg.Store.Release(ctx, record); err != nil {
+					slog.ErrorContext(ctx, "releasing idempotency key failed", "error", err)
+				}
+				return
+			}
+
+			record.StatusCode = status
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.ResponseBody = recorder.body.Bytes()
+			if err := cfg.Store.Complete(ctx, record); err != nil {
+				// The response has been sent already, so ErrorHandler would
+				// skip the error; the client just won't get it replayed.
+				slog.ErrorContext(ctx, "storing idempotent response failed", "error", err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+func replay(c *gin.Context, existing *models.IdempotencyRecord, requestHash string) {
+	if existing.RequestHash != requestHash {
+		abortWithError(c, apperror.New(apperror.CodeIdempotencyReused, "Idempotency-Key was already used for a different request"))
+		return
+	}
+	if existing.StatusCode == 0 {
+		abortWithError(c, apperror.Conflict("A request with this Idempotency-Key is still being processed"))
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+	c.Abort()
+}
+
+// hashRequest identifies a request by method, path and body.
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.Path)
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
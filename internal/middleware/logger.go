@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sensitiveHeaders are logged as [REDACTED] so credentials never reach the
+// logs.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// Logger writes one line per request to the default slog logger: a warning
+// for client errors, an error for server errors and info otherwise. The
+// request headers are included at debug level, with credentials redacted.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		ctx := c.Request.Context()
+		logger := slog.Default()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, slog.Any("headers", RedactHeaders(c.Request.Header)))
+		}
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// RedactHeaders flattens h for logging, replacing the values of sensitive
+// headers with [REDACTED].
+func RedactHeaders(h http.Header) map[string]string {
+	redacted := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = "[REDACTED]"
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+// Recovery turns a panic in a handler into a 500 response and logs it with
+// its stack trace.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			slog.ErrorContext(c.Request.Context(), "panic recovered",
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			appErr := apperror.Internal(nil)
+			c.AbortWithStatusJSON(StatusFor(appErr), ErrorResponse{
+				Code:      appErr.Code,
+				Message:   appErr.Message,
+				RequestID: GetRequestID(c),
+			})
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"time"
+
+	"gorepositorytest/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the method, route template, status and duration of every
+// request. It should run before the other middleware so it sees the final
+// status, including errors written by ErrorHandler and Recovery.
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		m.ObserveRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
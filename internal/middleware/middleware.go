@@ -1,33 +1,12 @@
 package middleware
 
 import (
-	"net/http"
-	"strings"
-
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterMiddlewares(r *gin.Engine) {
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-}
-
-func Authenticate() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "missing or malformed jwt"})
-			c.Abort()
-			return
-		}
-
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token != "12352" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid token"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
+	r.Use(RequestID())
+	r.Use(Logger())
+	r.Use(Recovery())
+	r.Use(ErrorHandler())
 }
@@ -1,25 +1,80 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/logging"
+	"gorepositorytest/internal/metrics"
+	"gorepositorytest/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func TestAuthenticate_ValidToken(t *testing.T) {
+var testSecret = []byte("test-secret")
+
+func testAuthConfig() AuthConfig {
+	return AuthConfig{
+		Algorithm: "HS256",
+		Secret:    testSecret,
+		Issuer:    "test-issuer",
+		Audience:  "test-audience",
+	}
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user-1",
+		Issuer:    "test-issuer",
+		Audience:  jwt.ClaimStrings{"test-audience"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func signHS256(t *testing.T, claims jwt.Claims, secret []byte) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func setupAuthRouter(cfg AuthConfig) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.New()
-	r.Use(Authenticate())
+	r.Use(ErrorHandler())
+	r.Use(Authenticate(cfg))
 	r.GET("/protected", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
+		claims, _ := GetClaims(c)
+		c.JSON(http.StatusOK, gin.H{"message": "authorized", "sub": claims.Subject})
 	})
+	return r
+}
+
+func TestAuthenticate_ValidToken(t *testing.T) {
+	r := setupAuthRouter(testAuthConfig())
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
-	req.Header.Set("Authorization", "Bearer 12352")
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, validClaims(), testSecret))
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -30,16 +85,13 @@ func TestAuthenticate_ValidToken(t *testing.T) {
 	if !strings.Contains(w.Body.String(), "authorized") {
 		t.Errorf("Expected response to contain 'authorized', got %s", w.Body.String())
 	}
+	if !strings.Contains(w.Body.String(), "user-1") {
+		t.Errorf("Expected claims subject 'user-1' in context, got %s", w.Body.String())
+	}
 }
 
 func TestAuthenticate_InvalidToken(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	r := gin.New()
-	r.Use(Authenticate())
-	r.GET("/protected", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
-	})
+	r := setupAuthRouter(testAuthConfig())
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
 	req.Header.Set("Authorization", "Bearer wrong-token")
@@ -56,13 +108,7 @@ func TestAuthenticate_InvalidToken(t *testing.T) {
 }
 
 func TestAuthenticate_MissingAuthHeader(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	r := gin.New()
-	r.Use(Authenticate())
-	r.GET("/protected", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
-	})
+	r := setupAuthRouter(testAuthConfig())
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
 	w := httptest.NewRecorder()
@@ -78,13 +124,7 @@ func TestAuthenticate_MissingAuthHeader(t *testing.T) {
 }
 
 func TestAuthenticate_EmptyAuthHeader(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	r := gin.New()
-	r.Use(Authenticate())
-	r.GET("/protected", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
-	})
+	r := setupAuthRouter(testAuthConfig())
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
 	req.Header.Set("Authorization", "")
@@ -101,16 +141,44 @@ func TestAuthenticate_EmptyAuthHeader(t *testing.T) {
 }
 
 func TestAuthenticate_MalformedAuthHeader(t *testing.T) {
-	gin.SetMode(gin.TestMode)
+	r := setupAuthRouter(testAuthConfig())
+	token := signHS256(t, validClaims(), testSecret)
 
-	r := gin.New()
-	r.Use(Authenticate())
-	r.GET("/protected", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
-	})
+	tests := []struct {
+		name     string
+		header   string
+		status   int
+		contains string
+	}{
+		{"no scheme", "InvalidFormat", http.StatusUnauthorized, "missing or malformed jwt"},
+		{"bare token", token, http.StatusUnauthorized, "missing or malformed jwt"},
+		{"basic scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "missing or malformed jwt"},
+		{"lowercase scheme", "bearer " + token, http.StatusOK, "user-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", tt.header)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("Expected response to contain '%s', got %s", tt.contains, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthenticate_BearerWithoutToken(t *testing.T) {
+	r := setupAuthRouter(testAuthConfig())
 
 	req, _ := http.NewRequest("GET", "/protected", nil)
-	req.Header.Set("Authorization", "InvalidFormat")
+	req.Header.Set("Authorization", "Bearer ")
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -118,22 +186,224 @@ func TestAuthenticate_MalformedAuthHeader(t *testing.T) {
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
-	if !strings.Contains(w.Body.String(), "invalid token") {
-		t.Errorf("Expected response to contain 'invalid token', got %s", w.Body.String())
+	if !strings.Contains(w.Body.String(), "missing or malformed jwt") {
+		t.Errorf("Expected response to contain 'missing or malformed jwt', got %s", w.Body.String())
 	}
 }
 
-func TestAuthenticate_BearerWithoutToken(t *testing.T) {
+func TestAuthenticate_RejectedTokens(t *testing.T) {
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"another-api"}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired token", signHS256(t, expired, testSecret)},
+		{"wrongly signed token", signHS256(t, validClaims(), []byte("other-secret"))},
+		{"missing sub claim", signHS256(t, noSubject, testSecret)},
+		{"missing exp claim", signHS256(t, noExpiry, testSecret)},
+		{"wrong issuer", signHS256(t, wrongIssuer, testSecret)},
+		{"wrong audience", signHS256(t, wrongAudience, testSecret)},
+		{"unsigned token", func() string {
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return token
+		}()},
+	}
+
+	r := setupAuthRouter(testAuthConfig())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), "invalid token") {
+				t.Errorf("Expected response to contain 'invalid token', got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthenticate_ClockSkew(t *testing.T) {
+	claims := validClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+
+	cfg := testAuthConfig()
+	cfg.ClockSkew = time.Minute
+	r := setupAuthRouter(cfg)
+
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, claims, testSecret))
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d within clock skew, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestAuthenticate_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	cfg := testAuthConfig()
+	cfg.Algorithm = "RS256"
+	cfg.Secret = nil
+	cfg.PublicKey = &key.PublicKey
+	r := setupAuthRouter(cfg)
+
+	t.Run("valid RS256 token", func(t *testing.T) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims()).SignedString(key)
+
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("HS256 token rejected", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+signHS256(t, validClaims(), testSecret))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
+
+func TestNewAuthConfig(t *testing.T) {
+	t.Run("HS256 secret", func(t *testing.T) {
+		cfg, err := NewAuthConfig("HS256", []byte("secret"), "issuer", "", 30*time.Second)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if string(cfg.Secret) != "secret" || cfg.Issuer != "issuer" || cfg.ClockSkew != 30*time.Second {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("RS256 public key", func(t *testing.T) {
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+		cfg, err := NewAuthConfig("RS256", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "", "", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cfg.PublicKey == nil || cfg.PublicKey.N.Cmp(key.PublicKey.N) != 0 {
+			t.Error("Expected public key to be parsed")
+		}
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		if _, err := NewAuthConfig("HS256", nil, "", "", 0); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		if _, err := NewAuthConfig("RS256", []byte("not a key"), "", "", 0); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		if _, err := NewAuthConfig("ES256", []byte("secret"), "", "", 0); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestRequireRole(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.New()
-	r.Use(Authenticate())
-	r.GET("/protected", func(c *gin.Context) {
+	r.Use(ErrorHandler())
+	r.Use(Authenticate(testAuthConfig()))
+	r.POST("/admin", RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
+	})
+	r.PUT("/staff", RequireRole(RoleStaff, RoleAdmin), func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
 	})
 
-	req, _ := http.NewRequest("GET", "/protected", nil)
-	req.Header.Set("Authorization", "Bearer ")
+	tokenWithRoles := func(roles ...string) string {
+		return signHS256(t, Claims{RegisteredClaims: validClaims(), Roles: roles}, testSecret)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		expectedCode int
+	}{
+		{"admin allowed on admin route", "POST", "/admin", tokenWithRoles(RoleAdmin), http.StatusOK},
+		{"staff denied on admin route", "POST", "/admin", tokenWithRoles(RoleStaff), http.StatusForbidden},
+		{"customer denied on admin route", "POST", "/admin", tokenWithRoles(RoleCustomer), http.StatusForbidden},
+		{"no roles denied on admin route", "POST", "/admin", tokenWithRoles(), http.StatusForbidden},
+		{"staff allowed on staff route", "PUT", "/staff", tokenWithRoles(RoleStaff), http.StatusOK},
+		{"admin allowed on staff route", "PUT", "/staff", tokenWithRoles(RoleCustomer, RoleAdmin), http.StatusOK},
+		{"customer denied on staff route", "PUT", "/staff", tokenWithRoles(RoleCustomer), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode == http.StatusForbidden && !strings.Contains(w.Body.String(), "insufficient permissions") {
+				t.Errorf("Expected response to contain 'insufficient permissions', got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireRole_WithoutAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/admin", RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
+	})
+
+	req, _ := http.NewRequest("GET", "/admin", nil)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -141,7 +411,690 @@ func TestAuthenticate_BearerWithoutToken(t *testing.T) {
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
-	if !strings.Contains(w.Body.String(), "invalid token") {
-		t.Errorf("Expected response to contain 'invalid token', got %s", w.Body.String())
+}
+
+func TestRequireRole_WithoutErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Authenticate(testAuthConfig()))
+	r.GET("/admin", RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "authorized"})
+	})
+
+	req, _ := http.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, Claims{RegisteredClaims: validClaims(), Roles: []string{RoleCustomer}}, testSecret))
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(ErrorHandler())
+	r.GET("/missing", func(c *gin.Context) {
+		c.Error(apperror.NotFound("Order not found"))
+	})
+	r.GET("/invalid", func(c *gin.Context) {
+		c.Error(apperror.Validation("Validation failed", []string{"name"}))
+	})
+	r.GET("/stock", func(c *gin.Context) {
+		c.Error(fmt.Errorf("placing order: %w", apperror.InsufficientStock("Insufficient stock for product: Widget")))
+	})
+	r.GET("/broken", func(c *gin.Context) {
+		c.Error(errors.New("pq: password authentication failed for user devuser"))
+	})
+	r.GET("/slow", func(c *gin.Context) {
+		c.Error(fmt.Errorf("listing orders: %w", context.DeadlineExceeded))
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+
+	tests := []struct {
+		path         string
+		expectedCode int
+		expected     ErrorResponse
+	}{
+		{"/missing", http.StatusNotFound, ErrorResponse{Code: apperror.CodeNotFound, Message: "Order not found"}},
+		{"/invalid", http.StatusUnprocessableEntity, ErrorResponse{Code: apperror.CodeValidation, Message: "Validation failed", Details: []any{"name"}}},
+		{"/stock", http.StatusConflict, ErrorResponse{Code: apperror.CodeInsufficientStock, Message: "Insufficient stock for product: Widget"}},
+		{"/broken", http.StatusInternalServerError, ErrorResponse{Code: apperror.CodeInternal, Message: "Internal server error"}},
+		{"/slow", http.StatusGatewayTimeout, ErrorResponse{Code: apperror.CodeTimeout, Message: "Request timed out"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			tt.expected.RequestID = "req-123"
+			if !reflect.DeepEqual(response, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, response)
+			}
+		})
+	}
+
+	t.Run("reports errors after the deadline as timeouts", func(t *testing.T) {
+		r := gin.New()
+		r.Use(ErrorHandler())
+		r.Use(QueryTimeout(time.Millisecond))
+		r.GET("/", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.Error(errors.New("canceling query due to user request"))
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, w.Code)
+		}
+	})
+
+	t.Run("leaves successful responses alone", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/ok", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "ok") {
+			t.Errorf("Expected untouched 200 response, got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	t.Run("echoes the caller's ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Header().Get(RequestIDHeader) != "abc-123" || w.Body.String() != "abc-123" {
+			t.Errorf("Expected request ID 'abc-123', got header %q body %q", w.Header().Get(RequestIDHeader), w.Body.String())
+		}
+	})
+
+	t.Run("generates an ID", func(t *testing.T) {
+		for _, header := range []string{"", "has spaces", strings.Repeat("a", 200)} {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set(RequestIDHeader, header)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id == header || w.Body.String() != id {
+				t.Errorf("%q: expected a generated request ID, got header %q body %q", header, id, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("stores the ID in the request context", func(t *testing.T) {
+		r := gin.New()
+		r.Use(RequestID())
+		r.GET("/", func(c *gin.Context) {
+			c.String(http.StatusOK, logging.RequestID(c.Request.Context()))
+		})
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Body.String() != "abc-123" {
+			t.Errorf("Expected request ID 'abc-123' in the context, got %q", w.Body.String())
+		}
+	})
+}
+
+// captureLogs sends the default slog logger to a buffer at level for the
+// rest of the test.
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, level))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Expected JSON logs, got %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(Logger())
+	r.Use(ErrorHandler())
+	r.GET("/products/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.Error(errors.New("connection refused"))
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	t.Run("logs each request with its ID", func(t *testing.T) {
+		buf := captureLogs(t, "info")
+		req, _ := http.NewRequest("GET", "/products/7?fields=name", nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		req.Header.Set("Authorization", "Bearer secret-token")
+
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		records := decodeLogs(t, buf)
+		if len(records) != 1 {
+			t.Fatalf("Expected 1 line, got %v", records)
+		}
+		record := records[0]
+		if record["msg"] != "request" || record["level"] != "INFO" || record["request_id"] != "req-123" {
+			t.Errorf("Expected an info line with the request ID, got %v", record)
+		}
+		if record["route"] != "/products/:id" || record["path"] != "/products/7" || record["status"] != float64(http.StatusOK) {
+			t.Errorf("Expected the route, path and status, got %v", record)
+		}
+		if _, ok := record["headers"]; ok {
+			t.Errorf("Expected headers only at debug level, got %v", record["headers"])
+		}
+	})
+
+	t.Run("server errors are logged with the request ID", func(t *testing.T) {
+		buf := captureLogs(t, "info")
+		req, _ := http.NewRequest("GET", "/products/0", nil)
+		req.Header.Set(RequestIDHeader, "req-500")
+
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		records := decodeLogs(t, buf)
+		if len(records) != 2 {
+			t.Fatalf("Expected the failure and the request, got %v", records)
+		}
+		for _, record := range records {
+			if record["level"] != "ERROR" || record["request_id"] != "req-500" {
+				t.Errorf("Expected an error line with the request ID, got %v", record)
+			}
+		}
+		if records[0]["error"] != "connection refused" {
+			t.Errorf("Expected the cause to be logged, got %v", records[0])
+		}
+	})
+
+	t.Run("redacts sensitive headers at debug level", func(t *testing.T) {
+		buf := captureLogs(t, "debug")
+		req, _ := http.NewRequest("GET", "/products/7", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Cookie", "session=secret")
+		req.Header.Set("Accept", "application/json")
+
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		if strings.Contains(buf.String(), "secret") {
+			t.Errorf("Expected credentials to be redacted, got %s", buf.String())
+		}
+		records := decodeLogs(t, buf)
+		headers, _ := records[0]["headers"].(map[string]any)
+		if headers["Authorization"] != "[REDACTED]" || headers["Cookie"] != "[REDACTED]" || headers["Accept"] != "application/json" {
+			t.Errorf("Expected redacted headers, got %v", headers)
+		}
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := captureLogs(t, "info")
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(Recovery())
+	r.GET("/", func(c *gin.Context) {
+		panic("nil map")
+	})
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	var resp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusInternalServerError || resp.Code != apperror.CodeInternal || resp.RequestID != "req-123" {
+		t.Errorf("Expected an internal error response, got %d %s", w.Code, w.Body.String())
+	}
+	records := decodeLogs(t, buf)
+	if len(records) != 1 || records[0]["panic"] != "nil map" || records[0]["request_id"] != "req-123" || records[0]["stack"] == nil {
+		t.Errorf("Expected the panic to be logged with its stack, got %v", records)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deadline := func(timeout time.Duration) (bool, time.Duration) {
+		r := gin.New()
+		r.Use(QueryTimeout(timeout))
+
+		var ok bool
+		var remaining time.Duration
+		r.GET("/", func(c *gin.Context) {
+			var d time.Time
+			d, ok = c.Request.Context().Deadline()
+			remaining = time.Until(d)
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return ok, remaining
+	}
+
+	t.Run("sets a deadline", func(t *testing.T) {
+		ok, remaining := deadline(2 * time.Second)
+
+		if !ok || remaining <= 0 || remaining > 2*time.Second {
+			t.Errorf("Expected a deadline within 2s, got %v %v", ok, remaining)
+		}
+	})
+
+	t.Run("zero disables the deadline", func(t *testing.T) {
+		if ok, _ := deadline(0); ok {
+			t.Error("Expected no deadline")
+		}
+	})
+
+	t.Run("cancels the context after the handler", func(t *testing.T) {
+		r := gin.New()
+		r.Use(QueryTimeout(time.Minute))
+
+		var ctx context.Context
+		r.GET("/", func(c *gin.Context) {
+			ctx = c.Request.Context()
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		if ctx.Err() == nil {
+			t.Error("Expected the request context to be cancelled")
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CORS([]string{"https://shop.example.com"}))
+	r.GET("/products", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": []string{}})
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/products", nil)
+		req.Header.Set("Origin", "https://shop.example.com")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.com" {
+			t.Errorf("Expected allowed response, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/products", nil)
+		req.Header.Set("Origin", "https://shop.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), IdempotencyKeyHeader) {
+			t.Errorf("Expected %s to be allowed, got %q", IdempotencyKeyHeader, w.Header().Get("Access-Control-Allow-Headers"))
+		}
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/products", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers, got %v", w.Header())
+		}
+	})
+}
+
+// Mock idempotency store for testing
+type mockIdempotencyStore struct {
+	records     map[string]*models.IdempotencyRecord
+	expired     [][2]time.Time
+	releaseErr  error
+	completeErr error
+}
+
+func (m *mockIdempotencyStore) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	id := record.Scope + "/" + record.Key
+	if existing, ok := m.records[id]; ok {
+		copied := *existing
+		return &copied, nil
+	}
+	copied := *record
+	m.records[id] = &copied
+	return nil, nil
+}
+
+func (m *mockIdempotencyStore) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	if m.completeErr != nil {
+		return m.completeErr
+	}
+	copied := *record
+	m.records[record.Scope+"/"+record.Key] = &copied
+	return nil
+}
+
+func (m *mockIdempotencyStore) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	if m.releaseErr != nil {
+		return m.releaseErr
+	}
+	delete(m.records, record.Scope+"/"+record.Key)
+	return nil
+}
+
+func (m *mockIdempotencyStore) DeleteExpired(ctx context.Context, before, pendingBefore time.Time) error {
+	m.expired = append(m.expired, [2]time.Time{before, pendingBefore})
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(store *mockIdempotencyStore, calls *int) *gin.Engine {
+		r := gin.New()
+		r.Use(Recovery(), ErrorHandler())
+		r.Use(func(c *gin.Context) {
+			SetClaims(c, &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: c.GetHeader("X-User")}})
+			c.Next()
+		})
+		r.POST("/orders", Idempotency(IdempotencyConfig{Store: store, TTL: time.Hour, Lease: time.Minute}), func(c *gin.Context) {
+			*calls++
+			body, _ := io.ReadAll(c.Request.Body)
+			switch string(body) {
+			case `{"fail":true}`:
+				c.Error(apperror.InsufficientStock("Insufficient stock"))
+				return
+			case `{"panic":true}`:
+				panic("handler bug")
+			}
+			c.JSON(http.StatusCreated, gin.H{"call": *calls, "body": string(body)})
+		})
+		return r
+	}
+
+	send := func(r *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("replays the first response", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		first := send(r, "user-1", "key-1", `{"a":1}`)
+		second := send(r, "user-1", "key-1", `{"a":1}`)
+
+		if calls != 1 {
+			t.Errorf("Expected the handler to run once, ran %d times", calls)
+		}
+
+		if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+			t.Errorf("Expected replayed %d %s, got %d %s", first.Code, first.Body.String(), second.Code, second.Body.String())
+		}
+
+		if second.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+			t.Error("Expected only the replay to carry the replayed header")
+		}
+
+		if len(store.expired) != 2 || time.Since(store.expired[0][0]) < time.Hour {
+			t.Errorf("Expected keys older than the TTL to be purged, got %v", store.expired)
+		}
+
+		if lease := time.Since(store.expired[0][1]); lease < time.Minute || lease > time.Hour {
+			t.Errorf("Expected reservations older than the lease to be purged, got %v", store.expired)
+		}
+	})
+
+	t.Run("rejects a key reused with a different body", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		send(r, "user-1", "key-1", `{"a":1}`)
+		w := send(r, "user-1", "key-1", `{"a":2}`)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if !strings.Contains(w.Body.String(), string(apperror.CodeIdempotencyReused)) {
+			t.Errorf("Expected code %s, got %s", apperror.CodeIdempotencyReused, w.Body.String())
+		}
+
+		if calls != 1 {
+			t.Errorf("Expected the handler to run once, ran %d times", calls)
+		}
+	})
+
+	t.Run("keys are scoped to the caller", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		send(r, "user-1", "key-1", `{"a":1}`)
+		w := send(r, "user-2", "key-1", `{"a":1}`)
+
+		if calls != 2 || w.Header().Get(IdempotentReplayedHeader) != "" {
+			t.Errorf("Expected user-2 to get a fresh response, handler ran %d times", calls)
+		}
+	})
+
+	t.Run("request still in progress", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+		store.records["user-1/key-1"] = &models.IdempotencyRecord{
+			Scope:       "user-1",
+			Key:         "key-1",
+			RequestHash: hashRequest(httptest.NewRequest("POST", "/orders", nil), []byte(`{"a":1}`)),
+		}
+
+		w := send(r, "user-1", "key-1", `{"a":1}`)
+
+		if w.Code != http.StatusConflict || calls != 0 {
+			t.Errorf("Expected status %d without running the handler, got %d after %d calls", http.StatusConflict, w.Code, calls)
+		}
+	})
+
+	t.Run("failed requests can be retried", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		first := send(r, "user-1", "key-1", `{"fail":true}`)
+		send(r, "user-1", "key-1", `{"fail":true}`)
+
+		if first.Code != http.StatusConflict || calls != 2 {
+			t.Errorf("Expected both attempts to run, got status %d after %d calls", first.Code, calls)
+		}
+
+		if len(store.records) != 0 {
+			t.Errorf("Expected the key to be released, got %v", store.records)
+		}
+	})
+
+	t.Run("a failed release keeps the handler's error", func(t *testing.T) {
+		logs := captureLogs(t, "info")
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}, releaseErr: errors.New("connection reset")}
+		calls := 0
+		r := setup(store, &calls)
+
+		w := send(r, "user-1", "key-1", `{"fail":true}`)
+
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(apperror.CodeInsufficientStock)) {
+			t.Errorf("Expected status %d with code %s, got %d %s", http.StatusConflict, apperror.CodeInsufficientStock, w.Code, w.Body.String())
+		}
+
+		if !strings.Contains(logs.String(), "releasing idempotency key failed") || !strings.Contains(logs.String(), "connection reset") {
+			t.Errorf("Expected the release failure to be logged, got %s", logs.String())
+		}
+	})
+
+	t.Run("a failed complete is logged", func(t *testing.T) {
+		logs := captureLogs(t, "info")
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}, completeErr: errors.New("connection reset")}
+		calls := 0
+		r := setup(store, &calls)
+
+		w := send(r, "user-1", "key-1", `{"a":1}`)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		if !strings.Contains(logs.String(), "storing idempotent response failed") || !strings.Contains(logs.String(), "connection reset") {
+			t.Errorf("Expected the complete failure to be logged, got %s", logs.String())
+		}
+	})
+
+	t.Run("panicking requests can be retried", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		first := send(r, "user-1", "key-1", `{"panic":true}`)
+		second := send(r, "user-1", "key-1", `{"panic":true}`)
+
+		if first.Code != http.StatusInternalServerError || second.Code != http.StatusInternalServerError || calls != 2 {
+			t.Errorf("Expected both attempts to run, got statuses %d and %d after %d calls", first.Code, second.Code, calls)
+		}
+
+		if len(store.records) != 0 {
+			t.Errorf("Expected the key to be released, got %v", store.records)
+		}
+	})
+
+	t.Run("requests without a key are not stored", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		send(r, "user-1", "", `{"a":1}`)
+		send(r, "user-1", "", `{"a":1}`)
+
+		if calls != 2 || len(store.records) != 0 {
+			t.Errorf("Expected two unrecorded calls, got %d calls and %d records", calls, len(store.records))
+		}
+	})
+
+	t.Run("rejects overly long keys", func(t *testing.T) {
+		store := &mockIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+		calls := 0
+		r := setup(store, &calls)
+
+		w := send(r, "user-1", strings.Repeat("k", 256), `{"a":1}`)
+
+		if w.Code != http.StatusBadRequest || calls != 0 {
+			t.Errorf("Expected status %d without running the handler, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+
+	r := gin.New()
+	r.Use(Metrics(m))
+	r.Use(ErrorHandler())
+	r.GET("/products/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.Error(apperror.NotFound("Product not found"))
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
+	for _, path := range []string{"/products/1", "/products/2", "/products/0", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/products/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/products/:id",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected %s in:\n%s", want, w.Body.String())
+		}
 	}
 }
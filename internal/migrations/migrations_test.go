@@ -0,0 +1,374 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open gorm: %v", err)
+	}
+	return gormDB, mock
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_products", Up: "CREATE TABLE products (id bigserial)", Down: "DROP TABLE products"},
+	{Version: 2, Name: "add_stock", Up: "ALTER TABLE products ADD COLUMN stock bigint", Down: "ALTER TABLE products DROP COLUMN stock"},
+	{Version: 3, Name: "add_price", Up: "ALTER TABLE products ADD COLUMN price bigint", Down: "ALTER TABLE products DROP COLUMN price"},
+}
+
+// expectTable expects the lookup of the schema_migrations table.
+func expectTable(mock sqlmock.Sqlmock, exists bool) {
+	count := 0
+	if exists {
+		count = 1
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1`)).
+		WithArgs("schema_migrations", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+// expectApplied expects the schema_migrations table to be read and to hold
+// the given versions.
+func expectApplied(mock sqlmock.Sqlmock, versions ...int64) {
+	expectTable(mock, true)
+
+	rows := sqlmock.NewRows([]string{"version", "name", "applied_at"})
+	for _, version := range versions {
+		rows.AddRow(version, testMigrations[version-1].Name, time.Now())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "schema_migrations" ORDER BY version`)).
+		WillReturnRows(rows)
+}
+
+func expectCreateTable(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUp(mock sqlmock.Sqlmock, migration Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "schema_migrations" ("version","name","applied_at") VALUES ($1,$2,$3)`)).
+		WithArgs(migration.Version, migration.Name, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectDown(mock sqlmock.Sqlmock, migration Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migration.Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(migration.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func versions(migrations []Migration) []int64 {
+	var result []int64
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	t.Run("orders migrations by version", func(t *testing.T) {
+		migrations, err := Parse(fstest.MapFS{
+			"0010_add_stock.up.sql":         {Data: []byte("ALTER TABLE products ADD COLUMN stock bigint")},
+			"0010_add_stock.down.sql":       {Data: []byte("ALTER TABLE products DROP COLUMN stock")},
+			"0002_create_products.up.sql":   {Data: []byte("CREATE TABLE products (id bigserial)")},
+			"0002_create_products.down.sql": {Data: []byte("DROP TABLE products")},
+			"README.md":                     {Data: []byte("not a migration")},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Name != "add_stock" {
+			t.Fatalf("Expected versions 2 and 10, got %+v", migrations)
+		}
+		if migrations[0].Down != "DROP TABLE products" {
+			t.Errorf("Expected down SQL to be read, got %q", migrations[0].Down)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing down file", fstest.MapFS{"0001_create_products.up.sql": {Data: []byte("CREATE TABLE products ()")}}},
+		{"badly named file", fstest.MapFS{"create_products.sql": {Data: []byte("CREATE TABLE products ()")}}},
+		{"version used twice", fstest.MapFS{
+			"0001_create_products.up.sql": {Data: []byte("CREATE TABLE products ()")},
+			"0001_create_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.files); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	db, _ := setupTestDB(t)
+
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i, migration := range migrator.migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("Expected version %d, got %d_%s", i+1, migration.Version, migration.Name)
+		}
+	}
+	if migrator.Latest() < 6 {
+		t.Errorf("Expected the postgres migrations to be embedded, got latest version %d", migrator.Latest())
+	}
+}
+
+// TestSQLiteMigrations runs the embedded SQLite migrations for real, since
+// no server is needed.
+func TestSQLiteMigrations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := context.Background()
+
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) == 0 {
+		t.Errorf("Expected every migration to be pending, got %+v, %v", pending, err)
+	}
+	if db.Migrator().HasTable("schema_migrations") {
+		t.Error("Expected checking for pending migrations to leave the schema alone")
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected migrations to apply, got %v", err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("Expected nothing pending, got %+v, %v", pending, err)
+	}
+	for _, table := range []string{"products", "orders", "order_items", "idempotency_records"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("Expected table %s to exist", table)
+		}
+	}
+
+	if _, err := migrator.To(ctx, 0); err != nil {
+		t.Fatalf("Expected migrations to roll back, got %v", err)
+	}
+	if db.Migrator().HasTable("products") {
+		t.Error("Expected products to be dropped")
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	t.Run("applies pending migrations in order", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock, 1)
+		expectCreateTable(mock)
+		expectUp(mock, testMigrations[1])
+		expectUp(mock, testMigrations[2])
+
+		ran, err := migrator.Up(context.Background())
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := versions(ran); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+			t.Errorf("Expected versions 2 and 3 to run, got %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock)
+		expectCreateTable(mock)
+		expectUp(mock, testMigrations[0])
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(testMigrations[1].Up)).WillReturnError(errors.New("column already exists"))
+		mock.ExpectRollback()
+
+		ran, err := migrator.Up(context.Background())
+
+		if err == nil || !strings.Contains(err.Error(), "2_add_stock") {
+			t.Errorf("Expected error naming migration 2, got %v", err)
+		}
+		if got := versions(ran); len(got) != 1 || got[0] != 1 {
+			t.Errorf("Expected only version 1 to run, got %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	t.Run("rolls back the latest applied migration", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock, 1, 2)
+		expectDown(mock, testMigrations[1])
+
+		migration, err := migrator.Down(context.Background())
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if migration == nil || migration.Version != 2 {
+			t.Errorf("Expected version 2 to be rolled back, got %+v", migration)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("nothing applied", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock)
+
+		migration, err := migrator.Down(context.Background())
+
+		if err != nil || migration != nil {
+			t.Errorf("Expected nothing to be rolled back, got %+v, %v", migration, err)
+		}
+	})
+}
+
+func TestMigrator_To(t *testing.T) {
+	t.Run("rolls back newer migrations in reverse order", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock, 1, 2, 3)
+		expectCreateTable(mock)
+		expectDown(mock, testMigrations[2])
+		expectDown(mock, testMigrations[1])
+
+		ran, err := migrator.To(context.Background(), 1)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := versions(ran); len(got) != 2 || got[0] != 3 || got[1] != 2 {
+			t.Errorf("Expected versions 3 and 2 to be rolled back, got %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("applies up to the version", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectApplied(mock)
+		expectCreateTable(mock)
+		expectUp(mock, testMigrations[0])
+		expectUp(mock, testMigrations[1])
+
+		ran, err := migrator.To(context.Background(), 2)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := versions(ran); len(got) != 2 || got[1] != 2 {
+			t.Errorf("Expected versions 1 and 2 to run, got %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		db, _ := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		if _, err := migrator.To(context.Background(), 7); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, mock := setupTestDB(t)
+	migrator := &Migrator{db: db, migrations: testMigrations}
+
+	expectApplied(mock, 1)
+
+	statuses, err := migrator.Status(context.Background())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(statuses) != 3 || statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("Expected only version 1 to be applied, got %+v", statuses)
+	}
+}
+
+func TestMigrator_Pending(t *testing.T) {
+	t.Run("a missing table means nothing is applied", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		migrator := &Migrator{db: db, migrations: testMigrations}
+
+		expectTable(mock, false)
+
+		pending, err := migrator.Pending(context.Background())
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(pending) != 3 {
+			t.Errorf("Expected every migration to be pending, got %v", versions(pending))
+		}
+		// Any other statement, such as creating the table, fails the mock.
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+-- The tables of a fresh install, with amounts already in integer minor
+-- units. Databases created by AutoMigrate before migrations were versioned
+-- keep their tables thanks to IF NOT EXISTS, including the numeric price
+-- and total_amount columns that 0003_money_minor_units converts.
+CREATE TABLE IF NOT EXISTS products (
+    id          bigserial PRIMARY KEY,
+    name        text NOT NULL,
+    description text,
+    price       bigint NOT NULL,
+    stock       bigint DEFAULT 0,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id             bigserial PRIMARY KEY,
+    transaction_id text NOT NULL CONSTRAINT uni_orders_transaction_id UNIQUE,
+    total_amount   bigint NOT NULL,
+    status         text DEFAULT 'pending',
+    created_at     timestamptz,
+    updated_at     timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id         bigserial PRIMARY KEY,
+    order_id   bigint NOT NULL CONSTRAINT fk_orders_order_items REFERENCES orders (id),
+    product_id bigint NOT NULL CONSTRAINT fk_order_items_product REFERENCES products (id),
+    quantity   bigint NOT NULL,
+    price      bigint NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
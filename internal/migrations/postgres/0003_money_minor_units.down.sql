@@ -0,0 +1,6 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE order_items ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE orders ALTER COLUMN total_amount TYPE numeric USING total_amount / 100.0;
+ALTER TABLE products ALTER COLUMN price TYPE numeric USING price / 100.0;
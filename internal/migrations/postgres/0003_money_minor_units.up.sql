@@ -0,0 +1,20 @@
+-- Databases created by AutoMigrate hold amounts in numeric columns with
+-- decimal prices. Convert any that are left to integer minor units (cents);
+-- fresh installs get bigint columns from 0001 and have nothing to convert.
+DO $$
+DECLARE
+    col record;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND (table_name::text, column_name::text) IN (('products', 'price'), ('orders', 'total_amount'), ('order_items', 'price'))
+          AND data_type = 'numeric'
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE bigint USING round(%I * 100)', col.table_name, col.column_name, col.column_name);
+    END LOOP;
+END $$;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,7 @@
+-- Order items keep the product name they were ordered under. Copy it onto
+-- items created before they carried their own snapshot.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name text;
+
+UPDATE order_items
+SET product_name = COALESCE((SELECT name FROM products WHERE products.id = order_items.product_id), '')
+WHERE product_name IS NULL OR product_name = '';
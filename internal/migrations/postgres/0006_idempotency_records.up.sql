@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    id              bigserial PRIMARY KEY,
+    scope           varchar(255) NOT NULL,
+    idempotency_key varchar(255) NOT NULL,
+    request_hash    varchar(64) NOT NULL,
+    status_code     bigint NOT NULL DEFAULT 0,
+    content_type    varchar(255),
+    response_body   bytea,
+    created_at      timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_scope_key ON idempotency_records (scope, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_records_created_at ON idempotency_records (created_at);
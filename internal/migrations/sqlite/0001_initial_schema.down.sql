@@ -0,0 +1,4 @@
+DROP TABLE idempotency_records;
+DROP TABLE order_items;
+DROP TABLE orders;
+DROP TABLE products;
@@ -0,0 +1,50 @@
+-- SQLite databases start from the current schema; the Postgres history
+-- before it only mattered for databases that already existed.
+CREATE TABLE products (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    name        text NOT NULL,
+    description text,
+    price       integer NOT NULL,
+    currency    varchar(3) NOT NULL DEFAULT 'USD',
+    stock       integer DEFAULT 0,
+    created_at  datetime,
+    updated_at  datetime,
+    deleted_at  datetime
+);
+CREATE INDEX idx_products_deleted_at ON products (deleted_at);
+
+CREATE TABLE orders (
+    id             integer PRIMARY KEY AUTOINCREMENT,
+    transaction_id text NOT NULL CONSTRAINT uni_orders_transaction_id UNIQUE,
+    customer_id    text,
+    total_amount   integer NOT NULL,
+    currency       varchar(3) NOT NULL DEFAULT 'USD',
+    status         text DEFAULT 'pending',
+    created_at     datetime,
+    updated_at     datetime
+);
+CREATE INDEX idx_orders_customer_id ON orders (customer_id);
+
+CREATE TABLE order_items (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    order_id     integer NOT NULL CONSTRAINT fk_orders_order_items REFERENCES orders (id),
+    product_id   integer NOT NULL CONSTRAINT fk_order_items_product REFERENCES products (id),
+    product_name text,
+    quantity     integer NOT NULL,
+    price        integer NOT NULL,
+    created_at   datetime,
+    updated_at   datetime
+);
+
+CREATE TABLE idempotency_records (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    scope           varchar(255) NOT NULL,
+    idempotency_key varchar(255) NOT NULL,
+    request_hash    varchar(64) NOT NULL,
+    status_code     integer NOT NULL DEFAULT 0,
+    content_type    varchar(255),
+    response_body   blob,
+    created_at      datetime
+);
+CREATE UNIQUE INDEX idx_idempotency_scope_key ON idempotency_records (scope, idempotency_key);
+CREATE INDEX idx_idempotency_records_created_at ON idempotency_records (created_at);
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key header so retries get the same response. A StatusCode of
+// zero means the first request is still being processed.
+type IdempotencyRecord struct {
+	ID           uint   `gorm:"primaryKey"`
+	Scope        string `gorm:"size:255;not null;uniqueIndex:idx_idempotency_scope_key"`
+	Key          string `gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_scope_key"`
+	RequestHash  string `gorm:"size:64;not null"`
+	StatusCode   int    `gorm:"not null;default:0"`
+	ContentType  string `gorm:"size:255"`
+	ResponseBody []byte
+	CreatedAt    time.Time `gorm:"index"`
+}
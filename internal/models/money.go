@@ -0,0 +1,129 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+// SupportedCurrencies are the ISO 4217 codes of the currencies with two
+// decimal places, the only ones Money can represent. Codes are upper case.
+var SupportedCurrencies = []string{
+	"AUD", "BRL", "CAD", "CHF", "CNY", "CZK", "DKK", "EUR", "GBP", "HKD",
+	"INR", "MXN", "NOK", "NZD", "PLN", "SEK", "SGD", "USD", "ZAR",
+}
+
+func IsSupportedCurrency(code string) bool {
+	return slices.Contains(SupportedCurrencies, code)
+}
+
+// Money is an amount in minor units (cents) of a currency with two decimal
+// places. It is encoded in JSON as a decimal number, e.g. 1099 as 10.99, so
+// clients keep seeing the same values as when prices were floats.
+type Money int64
+
+func (m Money) String() string {
+	sign := ""
+	// Negating in uint64 keeps math.MinInt64, which has no positive
+	// int64 counterpart, intact.
+	v := uint64(m)
+	if m < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Add returns m + other, or false if the sum does not fit in a Money.
+func (m Money) Add(other Money) (Money, bool) {
+	sum := m + other
+	if (other > 0 && sum < m) || (other < 0 && sum > m) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// Mul returns m * n, or false if the product does not fit in a Money.
+func (m Money) Mul(n int64) (Money, bool) {
+	product := m * Money(n)
+	if n != 0 && (product/Money(n) != m || (n == -1 && m == math.MinInt64)) {
+		return 0, false
+	}
+	return product, true
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// AmountError reports an amount that Money cannot hold.
+type AmountError struct {
+	Amount string
+	// Reason completes a sentence about the amount, e.g. "is too large".
+	Reason string
+}
+
+func (e *AmountError) Error() string {
+	return fmt.Sprintf("amount %q %s", e.Amount, e.Reason)
+}
+
+// ParseMoney parses a decimal amount such as "10.99" without going through
+// float64, so no precision is lost. Invalid amounts are reported as an
+// *AmountError.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if (whole == "" && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, &AmountError{Amount: s, Reason: "must be a decimal number"}
+	}
+	if len(frac) > 2 {
+		return 0, &AmountError{Amount: s, Reason: "must have at most 2 decimal places"}
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	// whole is all digits, so parsing can only fail on overflow.
+	units, err := strconv.ParseInt(whole, 10, 64)
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+	if err != nil || units > (math.MaxInt64-cents)/100 {
+		return 0, &AmountError{Amount: s, Reason: "is too large"}
+	}
+
+	amount := units*100 + cents
+	if negative {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
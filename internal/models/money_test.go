@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestMoney_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		expected string
+	}{
+		{1099, "10.99"},
+		{100, "1.00"},
+		{5, "0.05"},
+		{0, "0.00"},
+		{-250, "-2.50"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.amount)
+		if err != nil {
+			t.Errorf("Failed to marshal %d: %v", tt.amount, err)
+		}
+		if string(data) != tt.expected {
+			t.Errorf("Expected %d to marshal to %s, got %s", tt.amount, tt.expected, data)
+		}
+	}
+}
+
+func TestMoney_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Money
+	}{
+		{`10.99`, 1099},
+		{`10.9`, 1090},
+		{`10`, 1000},
+		{`"15.99"`, 1599},
+		{`0.07`, 7},
+		{`-2.5`, -250},
+		{`92233720368547758.07`, math.MaxInt64},
+		{`-92233720368547758.07`, -math.MaxInt64},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.input), &m); err != nil {
+			t.Errorf("Failed to unmarshal %s: %v", tt.input, err)
+		}
+		if m != tt.expected {
+			t.Errorf("Expected %s to unmarshal to %d, got %d", tt.input, tt.expected, m)
+		}
+	}
+}
+
+func TestMoney_UnmarshalJSON_Invalid(t *testing.T) {
+	for _, input := range []string{`10.999`, `"abc"`, `"1.x"`, `"."`, `1e3`, `"1.-5"`, `"--5"`, `"+5"`,
+		`184467440737095517`, `100000000000000000`, `92233720368547758.08`, `-92233720368547758.08`, `99999999999999999999`} {
+		var m Money
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("Expected error unmarshalling %s, got %d", input, m)
+		}
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	if sum, ok := Money(1099).Add(250); !ok || sum != 1349 {
+		t.Errorf("Expected 1349, got %d (ok %v)", sum, ok)
+	}
+	if product, ok := Money(1099).Mul(3); !ok || product != 3297 {
+		t.Errorf("Expected 3297, got %d (ok %v)", product, ok)
+	}
+
+	for name, ok := range map[string]bool{
+		"add":          second(Money(math.MaxInt64).Add(1)),
+		"add negative": second(Money(math.MinInt64).Add(-1)),
+		"mul":          second(Money(math.MaxInt64 / 2).Mul(3)),
+		"mul negative": second(Money(math.MinInt64).Mul(-1)),
+	} {
+		if ok {
+			t.Errorf("%s: expected overflow to be reported", name)
+		}
+	}
+}
+
+func second(_ Money, ok bool) bool {
+	return ok
+}
+
+func TestMoney_RoundTrip(t *testing.T) {
+	product := Product{Name: "Product 1", Price: 1099, Currency: "USD"}
+
+	data, err := json.Marshal(product)
+	if err != nil {
+		t.Fatalf("Failed to marshal product: %v", err)
+	}
+
+	var decoded Product
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal product: %v", err)
+	}
+
+	if decoded.Price != 1099 {
+		t.Errorf("Expected price 1099, got %d", decoded.Price)
+	}
+}
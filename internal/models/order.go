@@ -4,23 +4,61 @@ import (
 	"time"
 )
 
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+)
+
+// orderStatusTransitions lists the statuses an order may move to from each
+// status. Delivered and cancelled orders are final.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+}
+
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPending, OrderStatusConfirmed, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, next := range orderStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	ID            uint        `json:"id" gorm:"primaryKey"`
 	TransactionID string      `json:"transaction_id" gorm:"unique;not null"`
+	CustomerID    string      `json:"customer_id" gorm:"index"`
 	OrderItems    []OrderItem `json:"order_items" gorm:"foreignKey:OrderID"`
-	TotalAmount   float64     `json:"total_amount" gorm:"not null"`
+	TotalAmount   Money       `json:"total_amount" gorm:"not null"`
+	Currency      string      `json:"currency" gorm:"size:3;not null;default:USD"`
 	Status        string      `json:"status" gorm:"default:'pending'"` // pending, confirmed, shipped, delivered, cancelled
 	CreatedAt     time.Time   `json:"created_at"`
 	UpdatedAt     time.Time   `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	OrderID   uint      `json:"order_id" gorm:"not null"`
-	ProductID uint      `json:"product_id" gorm:"not null"`
-	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity  int       `json:"quantity" gorm:"not null"`
-	Price     float64   `json:"price" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint     `json:"id" gorm:"primaryKey"`
+	OrderID   uint     `json:"order_id" gorm:"not null"`
+	ProductID uint     `json:"product_id" gorm:"not null"`
+	Product   *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	// ProductName and Price are copied from the product when the order is
+	// placed, so later catalog changes do not rewrite past orders.
+	ProductName string    `json:"product_name"`
+	Quantity    int       `json:"quantity" gorm:"not null"`
+	Price       Money     `json:"price" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
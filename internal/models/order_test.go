@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestCanTransitionOrderStatus(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{OrderStatusPending, OrderStatusConfirmed, true},
+		{OrderStatusConfirmed, OrderStatusShipped, true},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusPending, OrderStatusCancelled, true},
+		{OrderStatusConfirmed, OrderStatusCancelled, true},
+		{OrderStatusShipped, OrderStatusCancelled, false},
+		{OrderStatusDelivered, OrderStatusCancelled, false},
+		{OrderStatusDelivered, OrderStatusPending, false},
+		{OrderStatusPending, OrderStatusShipped, false},
+		{OrderStatusConfirmed, OrderStatusPending, false},
+		{OrderStatusCancelled, OrderStatusPending, false},
+		{OrderStatusPending, OrderStatusPending, false},
+		{"unknown", OrderStatusConfirmed, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransitionOrderStatus(tt.from, tt.to); got != tt.expected {
+			t.Errorf("CanTransitionOrderStatus(%q, %q) = %v, expected %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestIsValidOrderStatus(t *testing.T) {
+	for _, status := range []string{"pending", "confirmed", "shipped", "delivered", "cancelled"} {
+		if !IsValidOrderStatus(status) {
+			t.Errorf("Expected %q to be valid", status)
+		}
+	}
+	for _, status := range []string{"", "lost", "PENDING"} {
+		if IsValidOrderStatus(status) {
+			t.Errorf("Expected %q to be invalid", status)
+		}
+	}
+}
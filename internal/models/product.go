@@ -1,13 +1,22 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Product struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name" gorm:"not null"`
 	Description string    `json:"description"`
-	Price       float64   `json:"price" gorm:"not null"`
+	Price       Money     `json:"price" gorm:"not null"`
+	Currency    string    `json:"currency" gorm:"size:3;not null;default:USD"`
 	Stock       int       `json:"stock" gorm:"default:0"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt marks an archived product. Archived products are hidden
+	// from the catalog but kept for the orders that reference them. The
+	// field is left out of the JSON of live products.
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitzero" gorm:"index"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestProduct_MarshalJSON(t *testing.T) {
+	t.Run("live products omit deleted_at", func(t *testing.T) {
+		data, err := json.Marshal(Product{ID: 1, Name: "Widget"})
+		if err != nil {
+			t.Fatalf("Failed to marshal product: %v", err)
+		}
+
+		if strings.Contains(string(data), "deleted_at") {
+			t.Errorf("Expected no deleted_at, got %s", data)
+		}
+	})
+
+	t.Run("archived products include deleted_at", func(t *testing.T) {
+		archivedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		data, err := json.Marshal(Product{ID: 1, Name: "Widget", DeletedAt: gorm.DeletedAt{Time: archivedAt, Valid: true}})
+		if err != nil {
+			t.Fatalf("Failed to marshal product: %v", err)
+		}
+
+		if !strings.Contains(string(data), `"deleted_at":"2025-03-01T12:00:00Z"`) {
+			t.Errorf("Expected deleted_at to be set, got %s", data)
+		}
+	})
+}
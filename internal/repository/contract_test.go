@@ -0,0 +1,670 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/migrations"
+	"gorepositorytest/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The contract tests describe how every ProductRepository, OrderRepository
+// and UnitOfWork behaves, and run against each backend with fresh, empty
+// storage per subtest. Postgres runs when TEST_DATABASE_DSN points at a
+// database the tests may empty.
+
+type contractRepos struct {
+	products ProductRepository
+	orders   OrderRepository
+	uow      UnitOfWork
+}
+
+type contractBackend struct {
+	name string
+	// env names the variable the backend needs; it is skipped without it.
+	env  string
+	open func(t *testing.T) contractRepos
+}
+
+var contractBackends = []contractBackend{
+	{"memory", "", func(t *testing.T) contractRepos {
+		store := NewMemoryStore()
+		return contractRepos{
+			products: NewMemoryProductRepository(store),
+			orders:   NewMemoryOrderRepository(store),
+			uow:      NewMemoryUnitOfWork(store),
+		}
+	}},
+	{"sqlite", "", func(t *testing.T) contractRepos {
+		return sqlContractRepos(setupSQLite(t))
+	}},
+	{"postgres", "TEST_DATABASE_DSN", func(t *testing.T) contractRepos {
+		return sqlContractRepos(setupPostgres(t, os.Getenv("TEST_DATABASE_DSN")))
+	}},
+}
+
+func sqlContractRepos(db *gorm.DB) contractRepos {
+	return contractRepos{
+		products: NewGormProductRepository(db),
+		orders:   NewGormOrderRepository(db),
+		uow:      NewGormUnitOfWork(db),
+	}
+}
+
+// setupPostgres migrates the database at dsn and empties its tables.
+func setupPostgres(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := db.Exec("TRUNCATE products, orders, order_items, idempotency_records RESTART IDENTITY CASCADE").Error; err != nil {
+		t.Fatalf("Failed to empty the tables: %v", err)
+	}
+	return db
+}
+
+// runContract runs test once per backend.
+func runContract(t *testing.T, test func(t *testing.T, open func(t *testing.T) contractRepos)) {
+	for _, backend := range contractBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.env != "" && os.Getenv(backend.env) == "" {
+				t.Skipf("%s is not set", backend.env)
+			}
+			test(t, backend.open)
+		})
+	}
+}
+
+func createProducts(t *testing.T, repo ProductRepository, products ...*models.Product) {
+	t.Helper()
+	for _, product := range products {
+		if err := repo.Create(context.Background(), product); err != nil {
+			t.Fatalf("Failed to create product %s: %v", product.Name, err)
+		}
+	}
+}
+
+func createOrders(t *testing.T, repo OrderRepository, orders ...*models.Order) {
+	t.Helper()
+	for _, order := range orders {
+		if err := repo.Create(context.Background(), order); err != nil {
+			t.Fatalf("Failed to create order %s: %v", order.TransactionID, err)
+		}
+	}
+}
+
+func productNames(products []models.Product) []string {
+	names := make([]string, len(products))
+	for i, product := range products {
+		names[i] = product.Name
+	}
+	return names
+}
+
+func transactionIDs(orders []models.Order) []string {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.TransactionID
+	}
+	return ids
+}
+
+func TestProductRepositoryContract(t *testing.T) {
+	ctx := context.Background()
+
+	runContract(t, func(t *testing.T, open func(t *testing.T) contractRepos) {
+		t.Run("create assigns IDs, timestamps and the default currency", func(t *testing.T) {
+			repo := open(t).products
+			first := &models.Product{Name: "Widget", Description: "Blue", Price: 1099, Stock: 3}
+			second := &models.Product{Name: "Gadget", Price: 250, Currency: "EUR"}
+			createProducts(t, repo, first, second)
+
+			if first.ID == 0 || second.ID <= first.ID {
+				t.Errorf("Expected increasing IDs, got %d and %d", first.ID, second.ID)
+			}
+			if first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+				t.Errorf("Expected timestamps to be set, got %+v", first)
+			}
+
+			stored, err := repo.GetByID(ctx, first.ID)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if stored.Name != "Widget" || stored.Description != "Blue" || stored.Price != 1099 || stored.Stock != 3 || stored.Currency != models.DefaultCurrency {
+				t.Errorf("Expected the created product, got %+v", stored)
+			}
+			if stored, _ := repo.GetByID(ctx, second.ID); stored == nil || stored.Currency != "EUR" {
+				t.Errorf("Expected currency EUR, got %+v", stored)
+			}
+		})
+
+		t.Run("missing product is not found", func(t *testing.T) {
+			repo := open(t).products
+
+			if _, err := repo.GetByID(ctx, 999); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error, got %v", err)
+			}
+		})
+
+		t.Run("update saves every field", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repo, product)
+
+			stored, _ := repo.GetByID(ctx, product.ID)
+			stored.Name = "Widget Pro"
+			stored.Price = 1999
+			stored.Stock = 0
+			if err := repo.Update(ctx, stored); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			updated, err := repo.GetByID(ctx, product.ID)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if updated.Name != "Widget Pro" || updated.Price != 1999 || updated.Stock != 0 {
+				t.Errorf("Expected the update to be saved, got %+v", updated)
+			}
+			if !updated.CreatedAt.Equal(stored.CreatedAt) {
+				t.Errorf("Expected CreatedAt %s to be kept, got %s", stored.CreatedAt, updated.CreatedAt)
+			}
+		})
+
+		t.Run("update never stores a missing or archived product", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repo, product)
+
+			stored, _ := repo.GetByID(ctx, product.ID)
+			if err := repo.Delete(ctx, product.ID); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			stored.Stock = 10
+			if err := repo.Update(ctx, stored); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error for an archived product, got %v", err)
+			}
+			if _, err := repo.GetByID(ctx, product.ID); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected the product to stay archived, got %v", err)
+			}
+
+			missing := &models.Product{ID: 999, Name: "Gadget", Price: 250, Currency: models.DefaultCurrency}
+			if err := repo.Update(ctx, missing); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error for a missing product, got %v", err)
+			}
+			if _, err := repo.GetByID(ctx, 999); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected no product to be created, got %v", err)
+			}
+		})
+
+		t.Run("patch keeps stock sold since the product was read", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Description: "Blue", Price: 1099, Stock: 5}
+			createProducts(t, repo, product)
+
+			read, _ := repo.GetByID(ctx, product.ID)
+			if err := repo.DecrementStock(ctx, product.ID, 3); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			name := "Widget Pro"
+			if err := repo.Patch(ctx, product.ID, ProductChanges{Name: &name}); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			patched, err := repo.GetByID(ctx, product.ID)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if patched.Name != "Widget Pro" || patched.Description != "Blue" || patched.Price != 1099 || patched.Stock != 2 {
+				t.Errorf("Expected only the name to change and stock 2, got %+v", patched)
+			}
+			if patched.UpdatedAt.Before(read.UpdatedAt) {
+				t.Errorf("Expected UpdatedAt to move forward from %s, got %s", read.UpdatedAt, patched.UpdatedAt)
+			}
+
+			stock := 7
+			if err := repo.Patch(ctx, product.ID, ProductChanges{Stock: &stock}); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if restocked, _ := repo.GetByID(ctx, product.ID); restocked == nil || restocked.Stock != 7 {
+				t.Errorf("Expected stock 7, got %+v", restocked)
+			}
+		})
+
+		t.Run("patch never touches a missing or archived product", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repo, product)
+			if err := repo.Delete(ctx, product.ID); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			stock := 10
+			for _, id := range []uint{product.ID, 999} {
+				if err := repo.Patch(ctx, id, ProductChanges{Stock: &stock}); apperror.CodeOf(err) != apperror.CodeNotFound {
+					t.Errorf("Expected not found error for product %d, got %v", id, err)
+				}
+			}
+			if _, err := repo.GetByID(ctx, product.ID); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected the product to stay archived, got %v", err)
+			}
+		})
+
+		t.Run("archived products leave the catalog until restored", func(t *testing.T) {
+			repo := open(t).products
+			kept := &models.Product{Name: "Gadget", Price: 250, Stock: 1}
+			archived := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repo, kept, archived)
+
+			if err := repo.Delete(ctx, archived.ID); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if _, err := repo.GetByID(ctx, archived.ID); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error, got %v", err)
+			}
+			all, _ := repo.GetAll(ctx)
+			listed, total, _ := repo.List(ctx, ProductListOptions{})
+			if len(all) != 1 || total != 1 || len(listed) != 1 || listed[0].ID != kept.ID {
+				t.Errorf("Expected only %s to be listed, got %v and %v", kept.Name, productNames(all), productNames(listed))
+			}
+			if err := repo.DecrementStock(ctx, archived.ID, 1); !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("Expected archived products not to be sold, got %v", err)
+			}
+			if err := repo.IncrementStock(ctx, archived.ID, 2); err != nil {
+				t.Errorf("Expected archived products to be restocked, got %v", err)
+			}
+
+			if err := repo.Restore(ctx, archived.ID); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			restored, err := repo.GetByID(ctx, archived.ID)
+			if err != nil || restored.Stock != 5 || restored.DeletedAt.Valid {
+				t.Errorf("Expected the restocked product back, got %+v, %v", restored, err)
+			}
+			if err := repo.Restore(ctx, archived.ID); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error for an active product, got %v", err)
+			}
+			if err := repo.Restore(ctx, 999); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error for a missing product, got %v", err)
+			}
+		})
+
+		t.Run("stock never goes below zero", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repo, product)
+
+			if err := repo.DecrementStock(ctx, product.ID, 2); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if err := repo.DecrementStock(ctx, product.ID, 2); !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("Expected insufficient stock, got %v", err)
+			}
+			if err := repo.DecrementStock(ctx, 999, 1); !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("Expected insufficient stock for a missing product, got %v", err)
+			}
+			if err := repo.IncrementStock(ctx, product.ID, 4); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if stored, _ := repo.GetByID(ctx, product.ID); stored == nil || stored.Stock != 5 {
+				t.Errorf("Expected stock 5, got %+v", stored)
+			}
+		})
+
+		t.Run("concurrent decrements sell each item once", func(t *testing.T) {
+			repo := open(t).products
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 10}
+			createProducts(t, repo, product)
+
+			var wg sync.WaitGroup
+			results := make(chan error, 25)
+			for range 25 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results <- repo.DecrementStock(ctx, product.ID, 1)
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			sold := 0
+			for err := range results {
+				switch {
+				case err == nil:
+					sold++
+				case !errors.Is(err, ErrInsufficientStock):
+					t.Errorf("Expected only insufficient stock errors, got %v", err)
+				}
+			}
+			if sold != 10 {
+				t.Errorf("Expected 10 decrements to succeed, got %d", sold)
+			}
+			if stored, _ := repo.GetByID(ctx, product.ID); stored == nil || stored.Stock != 0 {
+				t.Errorf("Expected stock 0, got %+v", stored)
+			}
+		})
+
+		t.Run("list filters, sorts and paginates", func(t *testing.T) {
+			repo := open(t).products
+			createProducts(t, repo,
+				&models.Product{Name: "Red Widget", Price: 900, Stock: 5},
+				&models.Product{Name: "Gadget", Price: 300, Stock: 1},
+				&models.Product{Name: "blue widget", Price: 500, Stock: 2},
+				&models.Product{Name: "Widget stand", Price: 100},
+			)
+
+			listed, total, err := repo.List(ctx, ProductListOptions{
+				Pagination: Pagination{Page: 2, PageSize: 1},
+				SortBy:     "price",
+				SortDesc:   true,
+				InStock:    true,
+				Search:     "WIDGET",
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if total != 2 || !slices.Equal(productNames(listed), []string{"blue widget"}) {
+				t.Errorf("Expected blue widget of 2 matches, got %v of %d", productNames(listed), total)
+			}
+
+			minPrice, maxPrice := models.Money(300), models.Money(500)
+			listed, total, _ = repo.List(ctx, ProductListOptions{SortBy: "name", MinPrice: &minPrice, MaxPrice: &maxPrice})
+			if total != 2 || !slices.Equal(productNames(listed), []string{"Gadget", "blue widget"}) {
+				t.Errorf("Expected Gadget and blue widget, got %v", productNames(listed))
+			}
+		})
+
+		t.Run("pages out of range never fail", func(t *testing.T) {
+			repo := open(t).products
+			createProducts(t, repo,
+				&models.Product{Name: "A", Price: 100},
+				&models.Product{Name: "B", Price: 200},
+			)
+
+			listed, total, err := repo.List(ctx, ProductListOptions{Pagination: Pagination{Page: 3, PageSize: 1}})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if total != 2 || len(listed) != 0 {
+				t.Errorf("Expected no products of 2 past the last page, got %v of %d", productNames(listed), total)
+			}
+
+			// The offset of this page overflows to a negative number.
+			listed, total, err = repo.List(ctx, ProductListOptions{Pagination: Pagination{Page: math.MaxInt/2 + 2, PageSize: 2}})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if total != 2 || !slices.Equal(productNames(listed), []string{"A", "B"}) {
+				t.Errorf("Expected a negative offset to start at the first product, got %v of %d", productNames(listed), total)
+			}
+		})
+
+		t.Run("search matches wildcards literally", func(t *testing.T) {
+			repo := open(t).products
+			createProducts(t, repo,
+				&models.Product{Name: "abc", Price: 100},
+				&models.Product{Name: "a_c", Price: 100},
+				&models.Product{Name: "100% cotton", Price: 100},
+				&models.Product{Name: `back\slash`, Price: 100},
+			)
+
+			for search, expected := range map[string]string{"a_c": "a_c", "0%": "100% cotton", `k\s`: `back\slash`} {
+				listed, _, err := repo.List(ctx, ProductListOptions{Search: search})
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if !slices.Equal(productNames(listed), []string{expected}) {
+					t.Errorf("Expected %q to match only %s, got %v", search, expected, productNames(listed))
+				}
+			}
+		})
+	})
+}
+
+func TestOrderRepositoryContract(t *testing.T) {
+	ctx := context.Background()
+
+	runContract(t, func(t *testing.T, open func(t *testing.T) contractRepos) {
+		t.Run("create stores the order with its items", func(t *testing.T) {
+			repos := open(t)
+			widget := &models.Product{Name: "Widget", Price: 1099, Stock: 5}
+			gadget := &models.Product{Name: "Gadget", Price: 250, Stock: 5}
+			createProducts(t, repos.products, widget, gadget)
+
+			order := &models.Order{
+				TransactionID: "TX_1",
+				CustomerID:    "user-1",
+				TotalAmount:   2448,
+				OrderItems: []models.OrderItem{
+					{ProductID: widget.ID, ProductName: widget.Name, Quantity: 2, Price: widget.Price},
+					{ProductID: gadget.ID, ProductName: gadget.Name, Quantity: 1, Price: gadget.Price},
+				},
+			}
+			createOrders(t, repos.orders, order)
+
+			if order.ID == 0 || order.CreatedAt.IsZero() {
+				t.Errorf("Expected an ID and timestamps, got %+v", order)
+			}
+			for _, item := range order.OrderItems {
+				if item.ID == 0 || item.OrderID != order.ID {
+					t.Errorf("Expected the item to be saved with the order, got %+v", item)
+				}
+			}
+
+			for name, get := range map[string]func() (*models.Order, error){
+				"by ID":             func() (*models.Order, error) { return repos.orders.GetByID(ctx, order.ID) },
+				"by transaction ID": func() (*models.Order, error) { return repos.orders.GetByTransactionID(ctx, "TX_1") },
+			} {
+				stored, err := get()
+				if err != nil {
+					t.Fatalf("Expected no error getting the order %s, got %v", name, err)
+				}
+				if stored.ID != order.ID || stored.CustomerID != "user-1" || stored.TotalAmount != 2448 {
+					t.Errorf("Expected the created order %s, got %+v", name, stored)
+				}
+				if stored.Status != models.OrderStatusPending || stored.Currency != models.DefaultCurrency {
+					t.Errorf("Expected a pending USD order %s, got %s %s", name, stored.Status, stored.Currency)
+				}
+				if len(stored.OrderItems) != 2 || stored.OrderItems[0].ProductName != "Widget" || stored.OrderItems[0].Quantity != 2 {
+					t.Errorf("Expected both items %s, got %+v", name, stored.OrderItems)
+				}
+			}
+		})
+
+		t.Run("missing order is not found", func(t *testing.T) {
+			repo := open(t).orders
+
+			if _, err := repo.GetByID(ctx, 999); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error, got %v", err)
+			}
+			if _, err := repo.GetByTransactionID(ctx, "TX_missing"); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error, got %v", err)
+			}
+		})
+
+		t.Run("transaction IDs are unique", func(t *testing.T) {
+			repo := open(t).orders
+			createOrders(t, repo, &models.Order{TransactionID: "TX_1", CustomerID: "user-1", TotalAmount: 100})
+
+			if err := repo.Create(ctx, &models.Order{TransactionID: "TX_1", CustomerID: "user-2", TotalAmount: 200}); err == nil {
+				t.Error("Expected a duplicate transaction ID to be rejected")
+			}
+			if orders, _ := repo.GetAll(ctx); len(orders) != 1 || orders[0].CustomerID != "user-1" {
+				t.Errorf("Expected only the first order to be stored, got %+v", orders)
+			}
+		})
+
+		t.Run("update saves every field", func(t *testing.T) {
+			repo := open(t).orders
+			order := &models.Order{TransactionID: "TX_1", CustomerID: "user-1", TotalAmount: 100}
+			createOrders(t, repo, order)
+
+			stored, _ := repo.GetByID(ctx, order.ID)
+			stored.Status = models.OrderStatusConfirmed
+			stored.TotalAmount = 150
+			if err := repo.Update(ctx, stored); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			updated, err := repo.GetByID(ctx, order.ID)
+			if err != nil || updated.Status != models.OrderStatusConfirmed || updated.TotalAmount != 150 {
+				t.Errorf("Expected the update to be saved, got %+v, %v", updated, err)
+			}
+		})
+
+		t.Run("update never creates a missing order", func(t *testing.T) {
+			repo := open(t).orders
+
+			missing := &models.Order{ID: 999, TransactionID: "TX_1", CustomerID: "user-1", TotalAmount: 100, Currency: models.DefaultCurrency}
+			if err := repo.Update(ctx, missing); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected not found error, got %v", err)
+			}
+			if orders, _ := repo.GetAll(ctx); len(orders) != 0 {
+				t.Errorf("Expected no order to be created, got %+v", orders)
+			}
+		})
+
+		t.Run("status changes only from the expected status", func(t *testing.T) {
+			repo := open(t).orders
+			order := &models.Order{TransactionID: "TX_1", CustomerID: "user-1", TotalAmount: 100}
+			createOrders(t, repo, order)
+
+			if err := repo.UpdateStatus(ctx, order.ID, models.OrderStatusPending, models.OrderStatusConfirmed); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if err := repo.UpdateStatus(ctx, order.ID, models.OrderStatusPending, models.OrderStatusCancelled); !errors.Is(err, ErrOrderStatusConflict) {
+				t.Errorf("Expected status conflict, got %v", err)
+			}
+			if err := repo.UpdateStatus(ctx, 999, models.OrderStatusPending, models.OrderStatusConfirmed); !errors.Is(err, ErrOrderStatusConflict) {
+				t.Errorf("Expected status conflict for a missing order, got %v", err)
+			}
+
+			if stored, _ := repo.GetByID(ctx, order.ID); stored == nil || stored.Status != models.OrderStatusConfirmed {
+				t.Errorf("Expected status confirmed, got %+v", stored)
+			}
+		})
+
+		t.Run("list filters, sorts and paginates", func(t *testing.T) {
+			repo := open(t).orders
+			createOrders(t, repo,
+				&models.Order{TransactionID: "TX_100", CustomerID: "user-1", TotalAmount: 1000},
+				&models.Order{TransactionID: "TX_200", CustomerID: "user-1", TotalAmount: 5000, Status: models.OrderStatusShipped},
+				&models.Order{TransactionID: "tx_300", CustomerID: "user-2", TotalAmount: 3000},
+				&models.Order{TransactionID: "TXA400", CustomerID: "user-1", TotalAmount: 2000},
+			)
+
+			tests := []struct {
+				name     string
+				opts     OrderListOptions
+				expected []string
+				total    int64
+			}{
+				{"customer and case-sensitive prefix", OrderListOptions{CustomerID: "user-1", TransactionIDPrefix: "TX_"}, []string{"TX_100", "TX_200"}, 2},
+				{"total range sorted", OrderListOptions{MinTotal: ptr(models.Money(2000)), MaxTotal: ptr(models.Money(4000)), SortBy: "total_amount", SortDesc: true}, []string{"tx_300", "TXA400"}, 2},
+				{"status paginated", OrderListOptions{Status: models.OrderStatusPending, Pagination: Pagination{Page: 2, PageSize: 2}}, []string{"TXA400"}, 3},
+				{"created after a time in another zone", OrderListOptions{CreatedFrom: ptr(time.Now().Add(-time.Hour).In(time.FixedZone("UTC+5", 5*60*60)))}, []string{"TX_100", "TX_200", "tx_300", "TXA400"}, 4},
+				{"created before a time in another zone", OrderListOptions{CreatedTo: ptr(time.Now().Add(-time.Hour).In(time.FixedZone("UTC-5", -5*60*60)))}, []string{}, 0},
+			}
+			for _, tt := range tests {
+				listed, total, err := repo.List(ctx, tt.opts)
+				if err != nil {
+					t.Fatalf("%s: expected no error, got %v", tt.name, err)
+				}
+				if total != tt.total || !slices.Equal(transactionIDs(listed), tt.expected) {
+					t.Errorf("%s: expected %v of %d, got %v of %d", tt.name, tt.expected, tt.total, transactionIDs(listed), total)
+				}
+			}
+		})
+	})
+}
+
+func TestUnitOfWorkContract(t *testing.T) {
+	ctx := context.Background()
+
+	runContract(t, func(t *testing.T, open func(t *testing.T) contractRepos) {
+		t.Run("commits every change", func(t *testing.T) {
+			repos := open(t)
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repos.products, product)
+
+			err := repos.uow.Do(ctx, func(tx Repositories) error {
+				if err := tx.Products.DecrementStock(ctx, product.ID, 2); err != nil {
+					return err
+				}
+				return tx.Orders.Create(ctx, &models.Order{
+					TransactionID: "TX_1",
+					TotalAmount:   2198,
+					OrderItems:    []models.OrderItem{{ProductID: product.ID, ProductName: product.Name, Quantity: 2, Price: product.Price}},
+				})
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if stored, _ := repos.products.GetByID(ctx, product.ID); stored == nil || stored.Stock != 1 {
+				t.Errorf("Expected stock 1, got %+v", stored)
+			}
+			if _, err := repos.orders.GetByTransactionID(ctx, "TX_1"); err != nil {
+				t.Errorf("Expected the order to be committed, got %v", err)
+			}
+		})
+
+		t.Run("rolls back every change on error", func(t *testing.T) {
+			repos := open(t)
+			product := &models.Product{Name: "Widget", Price: 1099, Stock: 3}
+			createProducts(t, repos.products, product)
+
+			failure := errors.New("payment declined")
+			err := repos.uow.Do(ctx, func(tx Repositories) error {
+				if err := tx.Products.DecrementStock(ctx, product.ID, 2); err != nil {
+					return err
+				}
+				if err := tx.Orders.Create(ctx, &models.Order{TransactionID: "TX_1", TotalAmount: 2198}); err != nil {
+					return err
+				}
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Errorf("Expected the unit of work's error, got %v", err)
+			}
+
+			if stored, _ := repos.products.GetByID(ctx, product.ID); stored == nil || stored.Stock != 3 {
+				t.Errorf("Expected stock 3, got %+v", stored)
+			}
+			if _, err := repos.orders.GetByTransactionID(ctx, "TX_1"); apperror.CodeOf(err) != apperror.CodeNotFound {
+				t.Errorf("Expected the order to be rolled back, got %v", err)
+			}
+		})
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
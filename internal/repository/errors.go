@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"errors"
+
+	"gorepositorytest/internal/apperror"
+
+	"gorm.io/gorm"
+)
+
+// notFound reports gorm's missing-record error as an apperror.NotFound with
+// message and passes every other error through.
+func notFound(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(message).WithCause(err)
+	}
+	return err
+}
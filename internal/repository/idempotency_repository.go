@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorepositorytest/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores record unless a record with the same scope and key
+	// exists, in which case the existing record is returned instead.
+	Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+	Release(ctx context.Context, record *models.IdempotencyRecord) error
+	// DeleteExpired deletes the records created before before, and the
+	// reservations still in progress created before pendingBefore.
+	DeleteExpired(ctx context.Context, before, pendingBefore time.Time) error
+}
+
+type gormIdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewGormIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &gormIdempotencyRepository{db: db}
+}
+
+func (r *gormIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyRecord
+	err := r.db.WithContext(ctx).Where("scope = ? AND idempotency_key = ?", record.Scope, record.Key).First(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete stores the response that retries will be answered with.
+func (r *gormIdempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.db.WithContext(ctx).Model(record).Select("status_code", "content_type", "response_body").Updates(record).Error
+}
+
+// Release forgets a reservation so the request can be retried.
+func (r *gormIdempotencyRepository) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.db.WithContext(ctx).Delete(record).Error
+}
+
+func (r *gormIdempotencyRepository) DeleteExpired(ctx context.Context, before, pendingBefore time.Time) error {
+	return r.db.WithContext(ctx).
+		Where("created_at < ? OR (status_code = 0 AND created_at < ?)", before, pendingBefore).
+		Delete(&models.IdempotencyRecord{}).Error
+}
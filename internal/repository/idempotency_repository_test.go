@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gorepositorytest/internal/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGormIdempotencyRepository_Reserve(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormIdempotencyRepository(db)
+
+	t.Run("reserves a new key", func(t *testing.T) {
+		record := &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "abc"}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "idempotency_records" ("scope","idempotency_key","request_hash","status_code","content_type","response_body","created_at") VALUES ($1,$2,$3,$4,$5,$6,$7) ON CONFLICT DO NOTHING RETURNING "id"`)).
+			WithArgs("user-1", "key-1", "abc", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		existing, err := repo.Reserve(context.Background(), record)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if existing != nil || record.ID != 1 {
+			t.Errorf("Expected reservation 1 and no existing record, got %d %+v", record.ID, existing)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("returns the existing record", func(t *testing.T) {
+		record := &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "abc"}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "idempotency_records"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "idempotency_records" WHERE scope = $1 AND idempotency_key = $2 ORDER BY "idempotency_records"."id" LIMIT $3`)).
+			WithArgs("user-1", "key-1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scope", "idempotency_key", "request_hash", "status_code", "response_body"}).
+				AddRow(7, "user-1", "key-1", "abc", 201, []byte(`{"id":1}`)))
+
+		existing, err := repo.Reserve(context.Background(), record)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if existing == nil || existing.ID != 7 || existing.StatusCode != 201 {
+			t.Errorf("Expected existing record 7, got %+v", existing)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("insert error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "idempotency_records"`)).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := repo.Reserve(context.Background(), &models.IdempotencyRecord{Scope: "user-1", Key: "key-2", RequestHash: "abc"})
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormIdempotencyRepository_Complete(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormIdempotencyRepository(db)
+
+	t.Run("stores the response", func(t *testing.T) {
+		record := &models.IdempotencyRecord{ID: 1, StatusCode: 201, ContentType: "application/json", ResponseBody: []byte(`{"id":1}`)}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "idempotency_records" SET "status_code"=$1,"content_type"=$2,"response_body"=$3 WHERE "id" = $4`)).
+			WithArgs(201, "application/json", []byte(`{"id":1}`), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		if err := repo.Complete(context.Background(), record); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormIdempotencyRepository_Cleanup(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormIdempotencyRepository(db)
+
+	t.Run("release", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_records" WHERE "idempotency_records"."id" = $1`)).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		if err := repo.Release(context.Background(), &models.IdempotencyRecord{ID: 3}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("delete expired", func(t *testing.T) {
+		before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		pendingBefore := before.Add(23 * time.Hour)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_records" WHERE created_at < $1 OR (status_code = 0 AND created_at < $2)`)).
+			WithArgs(before, pendingBefore).
+			WillReturnResult(sqlmock.NewResult(1, 4))
+		mock.ExpectCommit()
+
+		if err := repo.DeleteExpired(context.Background(), before, pendingBefore); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorepositorytest/internal/models"
+)
+
+// MemoryStore holds the data of the in-memory repositories. Repositories
+// built on the same store share its data, and NewMemoryUnitOfWork gives
+// them the all-or-nothing behaviour of a database transaction. It is safe
+// for concurrent use and meant for demos and tests; the data is lost when
+// the process exits.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data *memoryData
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newMemoryData()}
+}
+
+type memoryData struct {
+	products    map[uint]models.Product
+	orders      map[uint]models.Order
+	idempotency map[uint]models.IdempotencyRecord
+
+	lastProductID     uint
+	lastOrderID       uint
+	lastOrderItemID   uint
+	lastIdempotencyID uint
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		products:    map[uint]models.Product{},
+		orders:      map[uint]models.Order{},
+		idempotency: map[uint]models.IdempotencyRecord{},
+	}
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.products = make(map[uint]models.Product, len(d.products))
+	for id, product := range d.products {
+		c.products[id] = product
+	}
+	c.orders = make(map[uint]models.Order, len(d.orders))
+	for id, order := range d.orders {
+		c.orders[id] = copyOrder(order)
+	}
+	c.idempotency = make(map[uint]models.IdempotencyRecord, len(d.idempotency))
+	for id, record := range d.idempotency {
+		c.idempotency[id] = record
+	}
+	return &c
+}
+
+// memoryAccess runs repository calls against the store's data. Outside a
+// unit of work every call takes the store's lock; inside one the unit of
+// work already holds it.
+type memoryAccess interface {
+	read(ctx context.Context, fn func(d *memoryData) error) error
+	write(ctx context.Context, fn func(d *memoryData) error) error
+}
+
+func (s *MemoryStore) read(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.data)
+}
+
+func (s *MemoryStore) write(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+// memoryTx is the data a unit of work is changing.
+type memoryTx struct {
+	data *memoryData
+}
+
+func (tx memoryTx) read(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(tx.data)
+}
+
+func (tx memoryTx) write(ctx context.Context, fn func(d *memoryData) error) error {
+	return tx.read(ctx, fn)
+}
+
+type memoryUnitOfWork struct {
+	store *MemoryStore
+}
+
+// NewMemoryUnitOfWork runs units of work one at a time against a copy of
+// the store's data, which replaces the data only when fn succeeds.
+func NewMemoryUnitOfWork(store *MemoryStore) UnitOfWork {
+	return &memoryUnitOfWork{store: store}
+}
+
+func (u *memoryUnitOfWork) Do(ctx context.Context, fn func(repos Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	tx := memoryTx{data: u.store.data.clone()}
+	if err := fn(Repositories{
+		Products: &memoryProductRepository{access: tx},
+		Orders:   &memoryOrderRepository{access: tx},
+	}); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	u.store.data = tx.data
+	return nil
+}
+
+// page applies pagination to items that are already filtered and sorted.
+func page[T any](items []T, p Pagination) []T {
+	if p.PageSize <= 0 {
+		return items
+	}
+	// An offset that overflowed is negative; the SQL backends ignore it too.
+	start := min(max(p.Offset(), 0), len(items))
+	end := min(start+p.PageSize, len(items))
+	return items[start:end]
+}
+
+// sortItems orders items like orderClause: by compare, reversed when desc,
+// then by ID so ties are stable.
+func sortItems[T any](items []T, compare func(a, b T) int, desc bool, id func(T) uint) {
+	sort.SliceStable(items, func(i, j int) bool {
+		c := compare(items[i], items[j])
+		if desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return id(items[i]) < id(items[j])
+	})
+}
+
+// now matches the microsecond precision timestamps have in the database.
+func now() time.Time {
+	return time.Now().Round(time.Microsecond)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gorepositorytest/internal/models"
+)
+
+type memoryIdempotencyRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryIdempotencyRepository(store *MemoryStore) IdempotencyRepository {
+	return &memoryIdempotencyRepository{store: store}
+}
+
+func (r *memoryIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	var existing *models.IdempotencyRecord
+	err := r.store.write(ctx, func(d *memoryData) error {
+		for _, stored := range d.idempotency {
+			if stored.Scope == record.Scope && stored.Key == record.Key {
+				stored.ResponseBody = slices.Clone(stored.ResponseBody)
+				existing = &stored
+				return nil
+			}
+		}
+
+		d.lastIdempotencyID++
+		record.ID = d.lastIdempotencyID
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now()
+		}
+		d.idempotency[record.ID] = *record
+		return nil
+	})
+	return existing, err
+}
+
+// Complete stores the response that retries will be answered with.
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.store.write(ctx, func(d *memoryData) error {
+		stored, ok := d.idempotency[record.ID]
+		if !ok {
+			return nil
+		}
+		stored.StatusCode = record.StatusCode
+		stored.ContentType = record.ContentType
+		stored.ResponseBody = slices.Clone(record.ResponseBody)
+		d.idempotency[record.ID] = stored
+		return nil
+	})
+}
+
+// Release forgets a reservation so the request can be retried.
+func (r *memoryIdempotencyRepository) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.store.write(ctx, func(d *memoryData) error {
+		delete(d.idempotency, record.ID)
+		return nil
+	})
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context, before, pendingBefore time.Time) error {
+	return r.store.write(ctx, func(d *memoryData) error {
+		for id, record := range d.idempotency {
+			if record.CreatedAt.Before(before) || record.StatusCode == 0 && record.CreatedAt.Before(pendingBefore) {
+				delete(d.idempotency, id)
+			}
+		}
+		return nil
+	})
+}
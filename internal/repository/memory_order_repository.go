@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+)
+
+type memoryOrderRepository struct {
+	access memoryAccess
+}
+
+func NewMemoryOrderRepository(store *MemoryStore) OrderRepository {
+	return &memoryOrderRepository{access: store}
+}
+
+var orderSortCompare = map[string]func(a, b models.Order) int{
+	"created_at":   func(a, b models.Order) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"total_amount": func(a, b models.Order) int { return cmp.Compare(a.TotalAmount, b.TotalAmount) },
+	"status":       func(a, b models.Order) int { return cmp.Compare(a.Status, b.Status) },
+}
+
+func orderID(o models.Order) uint { return o.ID }
+
+// copyOrder returns order with its own copy of the items, so callers cannot
+// change the stored order through the slice.
+func copyOrder(order models.Order) models.Order {
+	order.OrderItems = slices.Clone(order.OrderItems)
+	return order
+}
+
+// allOrders returns copies of every order, ordered by ID.
+func (d *memoryData) allOrders() []models.Order {
+	orders := make([]models.Order, 0, len(d.orders))
+	for _, order := range d.orders {
+		orders = append(orders, copyOrder(order))
+	}
+	sortItems(orders, func(a, b models.Order) int { return cmp.Compare(a.ID, b.ID) }, false, orderID)
+	return orders
+}
+
+func (r *memoryOrderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.access.read(ctx, func(d *memoryData) error {
+		orders = d.allOrders()
+		return nil
+	})
+	return orders, err
+}
+
+func (r *memoryOrderRepository) List(ctx context.Context, opts OrderListOptions) ([]models.Order, int64, error) {
+	var orders []models.Order
+	err := r.access.read(ctx, func(d *memoryData) error {
+		for _, order := range d.allOrders() {
+			if opts.CustomerID != "" && order.CustomerID != opts.CustomerID ||
+				opts.Status != "" && order.Status != opts.Status ||
+				opts.CreatedFrom != nil && order.CreatedAt.Before(*opts.CreatedFrom) ||
+				opts.CreatedTo != nil && order.CreatedAt.After(*opts.CreatedTo) ||
+				opts.MinTotal != nil && order.TotalAmount < *opts.MinTotal ||
+				opts.MaxTotal != nil && order.TotalAmount > *opts.MaxTotal ||
+				!strings.HasPrefix(order.TransactionID, opts.TransactionIDPrefix) {
+				continue
+			}
+			orders = append(orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if compare, ok := orderSortCompare[opts.SortBy]; ok {
+		sortItems(orders, compare, opts.SortDesc, orderID)
+	} else {
+		sortItems(orders, func(a, b models.Order) int { return cmp.Compare(a.ID, b.ID) }, opts.SortDesc, orderID)
+	}
+	return page(orders, opts.Pagination), int64(len(orders)), nil
+}
+
+func (r *memoryOrderRepository) GetByID(ctx context.Context, id uint) (*models.Order, error) {
+	var order models.Order
+	err := r.access.read(ctx, func(d *memoryData) error {
+		stored, ok := d.orders[id]
+		if !ok {
+			return apperror.NotFound("Order not found")
+		}
+		order = copyOrder(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *memoryOrderRepository) GetByTransactionID(ctx context.Context, transactionID string) (*models.Order, error) {
+	var order *models.Order
+	err := r.access.read(ctx, func(d *memoryData) error {
+		for _, stored := range d.orders {
+			if stored.TransactionID == transactionID {
+				found := copyOrder(stored)
+				order = &found
+				return nil
+			}
+		}
+		return apperror.NotFound("Order not found")
+	})
+	return order, err
+}
+
+// Create stores the order under a new ID, or under order.ID when it is set,
+// gives its items new IDs and fills in the defaults the database would.
+// Transaction IDs are unique, as in the database.
+func (r *memoryOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		for _, stored := range d.orders {
+			if stored.TransactionID == order.TransactionID {
+				return fmt.Errorf("order with transaction ID %s already exists", order.TransactionID)
+			}
+		}
+		if order.ID == 0 {
+			order.ID = d.lastOrderID + 1
+		} else if _, ok := d.orders[order.ID]; ok {
+			return fmt.Errorf("order %d already exists", order.ID)
+		}
+		d.lastOrderID = max(d.lastOrderID, order.ID)
+
+		if order.Status == "" {
+			order.Status = models.OrderStatusPending
+		}
+		if order.Currency == "" {
+			order.Currency = models.DefaultCurrency
+		}
+		t := now()
+		if order.CreatedAt.IsZero() {
+			order.CreatedAt = t
+		}
+		if order.UpdatedAt.IsZero() {
+			order.UpdatedAt = t
+		}
+		d.saveItems(order, t)
+		d.orders[order.ID] = copyOrder(*order)
+		return nil
+	})
+}
+
+func (r *memoryOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		stored, ok := d.orders[order.ID]
+		if !ok {
+			return apperror.NotFound("Order not found")
+		}
+		if order.CreatedAt.IsZero() {
+			order.CreatedAt = stored.CreatedAt
+		}
+		order.UpdatedAt = now()
+		d.saveItems(order, order.UpdatedAt)
+		d.orders[order.ID] = copyOrder(*order)
+		return nil
+	})
+}
+
+// saveItems assigns IDs to the order's new items and links every item to
+// the order.
+func (d *memoryData) saveItems(order *models.Order, t time.Time) {
+	for i := range order.OrderItems {
+		item := &order.OrderItems[i]
+		if item.ID == 0 {
+			d.lastOrderItemID++
+			item.ID = d.lastOrderItemID
+			item.CreatedAt = t
+		}
+		item.OrderID = order.ID
+		item.UpdatedAt = t
+	}
+}
+
+// UpdateStatus moves the order to status to only while it is still in
+// status from.
+func (r *memoryOrderRepository) UpdateStatus(ctx context.Context, orderID uint, from, to string) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		order, ok := d.orders[orderID]
+		if !ok || order.Status != from {
+			return ErrOrderStatusConflict
+		}
+		order.Status = to
+		order.UpdatedAt = now()
+		d.orders[orderID] = order
+		return nil
+	})
+}
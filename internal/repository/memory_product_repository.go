@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type memoryProductRepository struct {
+	access memoryAccess
+}
+
+func NewMemoryProductRepository(store *MemoryStore) ProductRepository {
+	return &memoryProductRepository{access: store}
+}
+
+var productSortCompare = map[string]func(a, b models.Product) int{
+	"price":      func(a, b models.Product) int { return cmp.Compare(a.Price, b.Price) },
+	"name":       func(a, b models.Product) int { return cmp.Compare(a.Name, b.Name) },
+	"created_at": func(a, b models.Product) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+func productID(p models.Product) uint { return p.ID }
+
+// catalog returns the products that are not archived, ordered by ID.
+func (d *memoryData) catalog() []models.Product {
+	products := make([]models.Product, 0, len(d.products))
+	for _, product := range d.products {
+		if !product.DeletedAt.Valid {
+			products = append(products, product)
+		}
+	}
+	sortItems(products, func(a, b models.Product) int { return cmp.Compare(a.ID, b.ID) }, false, productID)
+	return products
+}
+
+func (r *memoryProductRepository) GetAll(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	err := r.access.read(ctx, func(d *memoryData) error {
+		products = d.catalog()
+		return nil
+	})
+	return products, err
+}
+
+func (r *memoryProductRepository) List(ctx context.Context, opts ProductListOptions) ([]models.Product, int64, error) {
+	var products []models.Product
+	err := r.access.read(ctx, func(d *memoryData) error {
+		search := strings.ToLower(opts.Search)
+		for _, product := range d.catalog() {
+			if opts.MinPrice != nil && product.Price < *opts.MinPrice ||
+				opts.MaxPrice != nil && product.Price > *opts.MaxPrice ||
+				opts.InStock && product.Stock <= 0 ||
+				search != "" && !strings.Contains(strings.ToLower(product.Name), search) {
+				continue
+			}
+			products = append(products, product)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if compare, ok := productSortCompare[opts.SortBy]; ok {
+		sortItems(products, compare, opts.SortDesc, productID)
+	} else {
+		sortItems(products, func(a, b models.Product) int { return cmp.Compare(a.ID, b.ID) }, opts.SortDesc, productID)
+	}
+	return page(products, opts.Pagination), int64(len(products)), nil
+}
+
+func (r *memoryProductRepository) GetByID(ctx context.Context, id uint) (*models.Product, error) {
+	var product models.Product
+	err := r.access.read(ctx, func(d *memoryData) error {
+		stored, ok := d.products[id]
+		if !ok || stored.DeletedAt.Valid {
+			return apperror.NotFound("Product not found")
+		}
+		product = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Create stores the product under a new ID, or under product.ID when it is
+// set, and fills in the defaults the database would.
+func (r *memoryProductRepository) Create(ctx context.Context, product *models.Product) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		if product.ID == 0 {
+			product.ID = d.lastProductID + 1
+		} else if _, ok := d.products[product.ID]; ok {
+			return fmt.Errorf("product %d already exists", product.ID)
+		}
+		d.lastProductID = max(d.lastProductID, product.ID)
+
+		if product.Currency == "" {
+			product.Currency = models.DefaultCurrency
+		}
+		t := now()
+		if product.CreatedAt.IsZero() {
+			product.CreatedAt = t
+		}
+		if product.UpdatedAt.IsZero() {
+			product.UpdatedAt = t
+		}
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r *memoryProductRepository) Update(ctx context.Context, product *models.Product) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		stored, ok := d.products[product.ID]
+		if !ok || stored.DeletedAt.Valid {
+			return apperror.NotFound("Product not found")
+		}
+		if product.CreatedAt.IsZero() {
+			product.CreatedAt = stored.CreatedAt
+		}
+		product.UpdatedAt = now()
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r *memoryProductRepository) Patch(ctx context.Context, id uint, changes ProductChanges) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		product, ok := d.products[id]
+		if !ok || product.DeletedAt.Valid {
+			return apperror.NotFound("Product not found")
+		}
+		if changes.Name != nil {
+			product.Name = *changes.Name
+		}
+		if changes.Description != nil {
+			product.Description = *changes.Description
+		}
+		if changes.Price != nil {
+			product.Price = *changes.Price
+		}
+		if changes.Currency != nil {
+			product.Currency = *changes.Currency
+		}
+		if changes.Stock != nil {
+			product.Stock = *changes.Stock
+		}
+		product.UpdatedAt = now()
+		d.products[id] = product
+		return nil
+	})
+}
+
+// Delete archives the product; it stays for past orders.
+func (r *memoryProductRepository) Delete(ctx context.Context, id uint) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		product, ok := d.products[id]
+		if !ok || product.DeletedAt.Valid {
+			return nil
+		}
+		product.DeletedAt.Time, product.DeletedAt.Valid = now(), true
+		d.products[id] = product
+		return nil
+	})
+}
+
+// Restore brings an archived product back into the catalog.
+func (r *memoryProductRepository) Restore(ctx context.Context, id uint) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		product, ok := d.products[id]
+		if !ok || !product.DeletedAt.Valid {
+			return apperror.NotFound("Archived product not found")
+		}
+		product.DeletedAt = gorm.DeletedAt{}
+		d.products[id] = product
+		return nil
+	})
+}
+
+// DecrementStock only succeeds when enough stock is left.
+func (r *memoryProductRepository) DecrementStock(ctx context.Context, id uint, quantity int) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		product, ok := d.products[id]
+		if !ok || product.DeletedAt.Valid || product.Stock < quantity {
+			return ErrInsufficientStock
+		}
+		product.Stock -= quantity
+		product.UpdatedAt = now()
+		d.products[id] = product
+		return nil
+	})
+}
+
+// IncrementStock also restocks archived products, like the GORM
+// repository.
+func (r *memoryProductRepository) IncrementStock(ctx context.Context, id uint, quantity int) error {
+	return r.access.write(ctx, func(d *memoryData) error {
+		product, ok := d.products[id]
+		if !ok {
+			return nil
+		}
+		product.Stock += quantity
+		product.UpdatedAt = now()
+		d.products[id] = product
+		return nil
+	})
+}
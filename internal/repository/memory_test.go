@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+)
+
+func TestMemoryProductRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("assigns IDs, timestamps and the default currency", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+
+		first := &models.Product{Name: "Widget", Price: 1099}
+		second := &models.Product{Name: "Gadget", Price: 250, Currency: "EUR"}
+		if err := repo.Create(ctx, first); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := repo.Create(ctx, second); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if first.ID != 1 || second.ID != 2 {
+			t.Errorf("Expected IDs 1 and 2, got %d and %d", first.ID, second.ID)
+		}
+		if first.Currency != models.DefaultCurrency || second.Currency != "EUR" {
+			t.Errorf("Expected currencies USD and EUR, got %s and %s", first.Currency, second.Currency)
+		}
+		if first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+			t.Errorf("Expected timestamps to be set, got %+v", first)
+		}
+	})
+
+	t.Run("archived products are hidden until restored", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+		repo.Create(ctx, &models.Product{Name: "Widget", Stock: 5})
+
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := repo.GetByID(ctx, 1); apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+		if err := repo.DecrementStock(ctx, 1, 1); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Expected archived product to be out of stock, got %v", err)
+		}
+		if err := repo.IncrementStock(ctx, 1, 2); err != nil {
+			t.Errorf("Expected archived product to be restocked, got %v", err)
+		}
+
+		if err := repo.Restore(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		product, err := repo.GetByID(ctx, 1)
+		if err != nil || product.Stock != 7 {
+			t.Errorf("Expected restored product with stock 7, got %+v, %v", product, err)
+		}
+		if err := repo.Restore(ctx, 1); apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("update of a missing product", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+
+		err := repo.Update(ctx, &models.Product{ID: 3, Name: "Widget"})
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("lists with filters, sort and pagination", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+		for _, product := range []models.Product{
+			{Name: "Blue Widget", Price: 1000, Stock: 1},
+			{Name: "Red Widget", Price: 3000, Stock: 0},
+			{Name: "Green Widget", Price: 2000, Stock: 4},
+			{Name: "Gadget", Price: 1500, Stock: 9},
+		} {
+			repo.Create(ctx, &product)
+		}
+		minPrice := models.Money(1000)
+
+		products, total, err := repo.List(ctx, ProductListOptions{
+			Pagination: Pagination{Page: 1, PageSize: 1},
+			SortBy:     "price",
+			SortDesc:   true,
+			MinPrice:   &minPrice,
+			InStock:    true,
+			Search:     "WIDGET",
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if total != 2 || len(products) != 1 || products[0].Name != "Green Widget" {
+			t.Errorf("Expected Green Widget of 2 matches, got %+v of %d", products, total)
+		}
+	})
+
+	t.Run("concurrent stock decrements never oversell", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+		repo.Create(ctx, &models.Product{Name: "Widget", Stock: 10})
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		sold := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := repo.DecrementStock(ctx, 1, 1); err == nil {
+					mu.Lock()
+					sold++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		product, _ := repo.GetByID(ctx, 1)
+		if sold != 10 || product.Stock != 0 {
+			t.Errorf("Expected 10 sold and stock 0, got %d sold and stock %d", sold, product.Stock)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		repo := NewMemoryProductRepository(NewMemoryStore())
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if _, err := repo.GetAll(cancelled); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestMemoryOrderRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("assigns IDs to the order and its items", func(t *testing.T) {
+		repo := NewMemoryOrderRepository(NewMemoryStore())
+		order := &models.Order{
+			TransactionID: "txn-1",
+			OrderItems:    []models.OrderItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+		}
+
+		if err := repo.Create(ctx, order); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if order.ID != 1 || order.Status != models.OrderStatusPending || order.Currency != models.DefaultCurrency {
+			t.Errorf("Expected pending order 1 in USD, got %+v", order)
+		}
+		if order.OrderItems[1].ID != 2 || order.OrderItems[1].OrderID != 1 {
+			t.Errorf("Expected item 2 of order 1, got %+v", order.OrderItems[1])
+		}
+
+		stored, err := repo.GetByTransactionID(ctx, "txn-1")
+		if err != nil || len(stored.OrderItems) != 2 {
+			t.Fatalf("Expected the stored order with 2 items, got %+v, %v", stored, err)
+		}
+		stored.OrderItems[0].Quantity = 99
+		if again, _ := repo.GetByID(ctx, 1); again.OrderItems[0].Quantity != 2 {
+			t.Errorf("Expected reads to return copies, got quantity %d", again.OrderItems[0].Quantity)
+		}
+	})
+
+	t.Run("transaction IDs are unique", func(t *testing.T) {
+		repo := NewMemoryOrderRepository(NewMemoryStore())
+		repo.Create(ctx, &models.Order{TransactionID: "txn-1"})
+
+		if err := repo.Create(ctx, &models.Order{TransactionID: "txn-1"}); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+
+	t.Run("missing order", func(t *testing.T) {
+		repo := NewMemoryOrderRepository(NewMemoryStore())
+
+		if _, err := repo.GetByID(ctx, 1); apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+		if _, err := repo.GetByTransactionID(ctx, "txn-1"); apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("status changes only from the expected status", func(t *testing.T) {
+		repo := NewMemoryOrderRepository(NewMemoryStore())
+		repo.Create(ctx, &models.Order{TransactionID: "txn-1"})
+
+		if err := repo.UpdateStatus(ctx, 1, models.OrderStatusPending, models.OrderStatusConfirmed); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := repo.UpdateStatus(ctx, 1, models.OrderStatusPending, models.OrderStatusCancelled); !errors.Is(err, ErrOrderStatusConflict) {
+			t.Errorf("Expected status conflict, got %v", err)
+		}
+	})
+
+	t.Run("lists with filters, sort and pagination", func(t *testing.T) {
+		repo := NewMemoryOrderRepository(NewMemoryStore())
+		for _, order := range []models.Order{
+			{TransactionID: "abc-1", CustomerID: "user-1", TotalAmount: 500},
+			{TransactionID: "abc-2", CustomerID: "user-1", TotalAmount: 1500},
+			{TransactionID: "xyz-1", CustomerID: "user-1", TotalAmount: 2500},
+			{TransactionID: "abc-3", CustomerID: "user-2", TotalAmount: 3500},
+		} {
+			repo.Create(ctx, &order)
+		}
+		from := time.Now().Add(-time.Hour)
+
+		orders, total, err := repo.List(ctx, OrderListOptions{
+			SortBy:              "total_amount",
+			SortDesc:            true,
+			CustomerID:          "user-1",
+			CreatedFrom:         &from,
+			TransactionIDPrefix: "abc",
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if total != 2 || orders[0].TransactionID != "abc-2" || orders[1].TransactionID != "abc-1" {
+			t.Errorf("Expected abc-2 then abc-1, got %+v", orders)
+		}
+	})
+}
+
+func TestMemoryUnitOfWork_Do(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	products := NewMemoryProductRepository(store)
+	orders := NewMemoryOrderRepository(store)
+	uow := NewMemoryUnitOfWork(store)
+	products.Create(ctx, &models.Product{Name: "Widget", Stock: 1})
+	products.Create(ctx, &models.Product{Name: "Gadget", Stock: 5})
+
+	t.Run("rolls back every change when fn fails", func(t *testing.T) {
+		err := uow.Do(ctx, func(repos Repositories) error {
+			if err := repos.Products.DecrementStock(ctx, 2, 5); err != nil {
+				return err
+			}
+			if err := repos.Orders.Create(ctx, &models.Order{TransactionID: "txn-1"}); err != nil {
+				return err
+			}
+			return repos.Products.DecrementStock(ctx, 1, 2)
+		})
+
+		if !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Expected insufficient stock, got %v", err)
+		}
+		if product, _ := products.GetByID(ctx, 2); product.Stock != 5 {
+			t.Errorf("Expected stock 5, got %d", product.Stock)
+		}
+		if all, _ := orders.GetAll(ctx); len(all) != 0 {
+			t.Errorf("Expected no orders, got %d", len(all))
+		}
+	})
+
+	t.Run("commits when fn succeeds", func(t *testing.T) {
+		err := uow.Do(ctx, func(repos Repositories) error {
+			if err := repos.Products.DecrementStock(ctx, 1, 1); err != nil {
+				return err
+			}
+			return repos.Orders.Create(ctx, &models.Order{TransactionID: "txn-1"})
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if product, _ := products.GetByID(ctx, 1); product.Stock != 0 {
+			t.Errorf("Expected stock 0, got %d", product.Stock)
+		}
+		if _, err := orders.GetByTransactionID(ctx, "txn-1"); err != nil {
+			t.Errorf("Expected the order to be stored, got %v", err)
+		}
+	})
+}
+
+func TestMemoryIdempotencyRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryIdempotencyRepository(NewMemoryStore())
+
+	record := &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "hash"}
+	if existing, err := repo.Reserve(ctx, record); err != nil || existing != nil {
+		t.Fatalf("Expected the key to be reserved, got %+v, %v", existing, err)
+	}
+
+	record.StatusCode, record.ResponseBody = 201, []byte(`{"id":1}`)
+	if err := repo.Complete(ctx, record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	existing, err := repo.Reserve(ctx, &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "hash"})
+	if err != nil || existing == nil || existing.StatusCode != 201 || string(existing.ResponseBody) != `{"id":1}` {
+		t.Errorf("Expected the completed record, got %+v, %v", existing, err)
+	}
+	if other, _ := repo.Reserve(ctx, &models.IdempotencyRecord{Scope: "user-2", Key: "key-1"}); other != nil {
+		t.Errorf("Expected keys to be scoped, got %+v", other)
+	}
+
+	pending := &models.IdempotencyRecord{Scope: "user-3", Key: "key-1", RequestHash: "hash"}
+	if existing, err := repo.Reserve(ctx, pending); err != nil || existing != nil {
+		t.Fatalf("Expected the key to be reserved, got %+v, %v", existing, err)
+	}
+	if err := repo.DeleteExpired(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if existing, _ := repo.Reserve(ctx, pending); existing != nil {
+		t.Errorf("Expected the abandoned reservation to be deleted, got %+v", existing)
+	}
+	if existing, _ := repo.Reserve(ctx, record); existing == nil {
+		t.Error("Expected the completed record to be kept until it expires")
+	}
+
+	if err := repo.DeleteExpired(ctx, time.Now().Add(time.Minute), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if existing, _ := repo.Reserve(ctx, record); existing != nil {
+		t.Errorf("Expected expired records to be deleted, got %+v", existing)
+	}
+}
@@ -1,50 +1,162 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
+
+	"gorepositorytest/internal/apperror"
 	"gorepositorytest/internal/models"
 
 	"gorm.io/gorm"
 )
 
+var ErrOrderStatusConflict = apperror.Conflict("Order status was changed by another request")
+
+// OrderListOptions filters, sorts and paginates OrderRepository.List.
+// Zero values disable the corresponding filter.
+type OrderListOptions struct {
+	Pagination
+	SortBy              string // created_at, total_amount or status; defaults to id
+	SortDesc            bool
+	CustomerID          string
+	Status              string
+	CreatedFrom         *time.Time
+	CreatedTo           *time.Time
+	MinTotal            *models.Money
+	MaxTotal            *models.Money
+	TransactionIDPrefix string
+}
+
+var orderSortColumns = map[string]string{
+	"created_at":   "created_at",
+	"total_amount": "total_amount",
+	"status":       "status",
+}
+
+func IsValidOrderSort(field string) bool {
+	_, ok := orderSortColumns[field]
+	return ok
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type OrderRepository interface {
-	GetAll() ([]models.Order, error)
-	GetByTransactionID(transactionID string) (*models.Order, error)
-	Create(order *models.Order) error
-	Update(order *models.Order) error
-	UpdateStatus(orderID uint, status string) error
+	GetAll(ctx context.Context) ([]models.Order, error)
+	List(ctx context.Context, opts OrderListOptions) ([]models.Order, int64, error)
+	GetByID(ctx context.Context, id uint) (*models.Order, error)
+	GetByTransactionID(ctx context.Context, transactionID string) (*models.Order, error)
+	Create(ctx context.Context, order *models.Order) error
+	Update(ctx context.Context, order *models.Order) error
+	UpdateStatus(ctx context.Context, orderID uint, from, to string) error
 }
 
-type postgresOrderRepository struct {
+type gormOrderRepository struct {
 	db *gorm.DB
 }
 
-func NewPostgresOrderRepository(db *gorm.DB) OrderRepository {
-	return &postgresOrderRepository{db: db}
+func NewGormOrderRepository(db *gorm.DB) OrderRepository {
+	return &gormOrderRepository{db: db}
 }
 
-func (r *postgresOrderRepository) GetAll() ([]models.Order, error) {
+func (r *gormOrderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Find(&orders).Error
+	err := r.db.WithContext(ctx).Preload("OrderItems").Find(&orders).Error
 	return orders, err
 }
 
-func (r *postgresOrderRepository) GetByTransactionID(transactionID string) (*models.Order, error) {
+// List returns one page of orders matching opts together with the total
+// number of matching orders.
+func (r *gormOrderRepository) List(ctx context.Context, opts OrderListOptions) ([]models.Order, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Order{})
+	if opts.CustomerID != "" {
+		query = query.Where("customer_id = ?", opts.CustomerID)
+	}
+	if opts.Status != "" {
+		query = query.Where("status = ?", opts.Status)
+	}
+	// SQLite compares times as text, which needs the bounds in UTC like
+	// the stored values.
+	if opts.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", opts.CreatedFrom.UTC())
+	}
+	if opts.CreatedTo != nil {
+		query = query.Where("created_at <= ?", opts.CreatedTo.UTC())
+	}
+	if opts.MinTotal != nil {
+		query = query.Where("total_amount >= ?", *opts.MinTotal)
+	}
+	if opts.MaxTotal != nil {
+		query = query.Where("total_amount <= ?", *opts.MaxTotal)
+	}
+	if opts.TransactionIDPrefix != "" {
+		query = query.Where(`transaction_id LIKE ? ESCAPE '\'`, likeEscaper.Replace(opts.TransactionIDPrefix)+"%")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order(orderClause(orderSortColumns, opts.SortBy, opts.SortDesc))
+	if opts.PageSize > 0 {
+		query = query.Limit(opts.PageSize).Offset(opts.Offset())
+	}
+
+	var orders []models.Order
+	if err := query.Preload("OrderItems").Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *gormOrderRepository) GetByID(ctx context.Context, id uint) (*models.Order, error) {
 	var order models.Order
-	err := r.db.Where("transaction_id = ?", transactionID).First(&order).Error
+	err := r.db.WithContext(ctx).Preload("OrderItems").First(&order, id).Error
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "Order not found")
 	}
 	return &order, nil
 }
 
-func (r *postgresOrderRepository) Create(order *models.Order) error {
-	return r.db.Create(order).Error
+func (r *gormOrderRepository) GetByTransactionID(ctx context.Context, transactionID string) (*models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("OrderItems").Where("transaction_id = ?", transactionID).First(&order).Error
+	if err != nil {
+		return nil, notFound(err, "Order not found")
+	}
+	return &order, nil
+}
+
+func (r *gormOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Create(order).Error
 }
 
-func (r *postgresOrderRepository) Update(order *models.Order) error {
-	return r.db.Save(order).Error
+// Update saves every field of an existing order. Unlike Save it never
+// inserts an order that does not exist.
+func (r *gormOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	result := r.db.WithContext(ctx).Model(order).Select("*").Omit("created_at").Updates(order)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NotFound("Order not found")
+	}
+	return nil
 }
 
-func (r *postgresOrderRepository) UpdateStatus(orderID uint, status string) error {
-	return r.db.Model(&models.Order{}).Where("id = ?", orderID).Update("status", status).Error
+// UpdateStatus moves the order to status to only while it is still in status
+// from, so two concurrent transitions cannot both succeed.
+func (r *gormOrderRepository) UpdateStatus(ctx context.Context, orderID uint, from, to string) error {
+	result := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("id = ? AND status = ?", orderID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStatusConflict
+	}
+	return nil
 }
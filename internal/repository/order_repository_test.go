@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"gorepositorytest/internal/apperror"
 	"gorepositorytest/internal/models"
 	"regexp"
 	"testing"
@@ -11,7 +13,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func TestPostgresOrderRepository_GetAll(t *testing.T) {
+func TestGormOrderRepository_GetAll(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -21,17 +23,24 @@ func TestPostgresOrderRepository_GetAll(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresOrderRepository(db)
+	repo := NewGormOrderRepository(db)
 
 	t.Run("successful get all orders", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "transaction_id", "total_amount", "status", "created_at", "updated_at"}).
-			AddRow(1, "TXN001", 99.99, "pending", time.Now(), time.Now()).
-			AddRow(2, "TXN002", 149.99, "confirmed", time.Now(), time.Now())
+			AddRow(1, "TXN001", 9999, "pending", time.Now(), time.Now()).
+			AddRow(2, "TXN002", 14999, "confirmed", time.Now(), time.Now())
+
+		items := sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "price"}).
+			AddRow(1, 1, 10, "Product 1", 2, 1099).
+			AddRow(2, 2, 11, "Product 2", 1, 1599)
 
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders"`)).
 			WillReturnRows(rows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_items" WHERE "order_items"."order_id" IN ($1,$2)`)).
+			WithArgs(1, 2).
+			WillReturnRows(items)
 
-		orders, err := repo.GetAll()
+		orders, err := repo.GetAll(context.Background())
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -49,6 +58,10 @@ func TestPostgresOrderRepository_GetAll(t *testing.T) {
 			t.Errorf("Expected second order status to be 'confirmed', got %s", orders[1].Status)
 		}
 
+		if len(orders[0].OrderItems) != 1 || orders[0].OrderItems[0].ProductName != "Product 1" {
+			t.Errorf("Expected first order to have item 'Product 1', got %+v", orders[0].OrderItems)
+		}
+
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("There were unfulfilled expectations: %s", err)
 		}
@@ -58,7 +71,7 @@ func TestPostgresOrderRepository_GetAll(t *testing.T) {
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders"`)).
 			WillReturnError(sql.ErrConnDone)
 
-		orders, err := repo.GetAll()
+		orders, err := repo.GetAll(context.Background())
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -74,7 +87,149 @@ func TestPostgresOrderRepository_GetAll(t *testing.T) {
 	})
 }
 
-func TestPostgresOrderRepository_GetByTransactionID(t *testing.T) {
+func TestGormOrderRepository_List(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormOrderRepository(db)
+
+	t.Run("filters, sorts and paginates", func(t *testing.T) {
+		from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)
+		minTotal := models.Money(1000)
+		maxTotal := models.Money(9999)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "orders" WHERE customer_id = $1 AND status = $2 AND created_at >= $3 AND created_at <= $4 AND total_amount >= $5 AND total_amount <= $6 AND transaction_id LIKE $7 ESCAPE '\'`)).
+			WithArgs("user-1", "shipped", from, to, 1000, 9999, `TX\_1%`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE customer_id = $1 AND status = $2 AND created_at >= $3 AND created_at <= $4 AND total_amount >= $5 AND total_amount <= $6 AND transaction_id LIKE $7 ESCAPE '\' ORDER BY total_amount DESC, id LIMIT $8`)).
+			WithArgs("user-1", "shipped", from, to, 1000, 9999, `TX\_1%`, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "customer_id", "total_amount", "status"}).
+				AddRow(1, "TX_1001", "user-1", 5000, "shipped"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_items" WHERE "order_items"."order_id" = $1`)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "price"}))
+
+		orders, total, err := repo.List(context.Background(), OrderListOptions{
+			Pagination:          Pagination{Page: 1, PageSize: 10},
+			SortBy:              "total_amount",
+			SortDesc:            true,
+			CustomerID:          "user-1",
+			Status:              "shipped",
+			CreatedFrom:         &from,
+			CreatedTo:           &to,
+			MinTotal:            &minTotal,
+			MaxTotal:            &maxTotal,
+			TransactionIDPrefix: "TX_1",
+		})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if total != 1 || len(orders) != 1 || orders[0].TransactionID != "TX_1001" {
+			t.Errorf("Expected order 'TX_1001', got %d %+v", total, orders)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("find error", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "orders"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" ORDER BY id LIMIT $1`)).
+			WithArgs(20).
+			WillReturnError(sql.ErrConnDone)
+
+		_, _, err := repo.List(context.Background(), OrderListOptions{Pagination: Pagination{Page: 1, PageSize: 20}})
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormOrderRepository_GetByID(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormOrderRepository(db)
+
+	t.Run("successful get by id", func(t *testing.T) {
+		row := sqlmock.NewRows([]string{"id", "transaction_id", "total_amount", "status", "created_at", "updated_at"}).
+			AddRow(1, "TXN001", 9999, "pending", time.Now(), time.Now())
+
+		items := sqlmock.NewRows([]string{"id", "order_id", "product_id", "quantity", "price"}).
+			AddRow(1, 1, 10, 2, 1099).
+			AddRow(2, 1, 11, 1, 1599)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "orders"."id" = $1 ORDER BY "orders"."id" LIMIT $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(row)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_items" WHERE "order_items"."order_id" = $1`)).
+			WithArgs(1).
+			WillReturnRows(items)
+
+		order, err := repo.GetByID(context.Background(), 1)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if order == nil || order.Status != "pending" {
+			t.Errorf("Expected pending order, got %+v", order)
+			return
+		}
+
+		if len(order.OrderItems) != 2 {
+			t.Errorf("Expected 2 order items, got %d", len(order.OrderItems))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("order not found", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "orders"."id" = $1 ORDER BY "orders"."id" LIMIT $2`)).
+			WithArgs(999, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		order, err := repo.GetByID(context.Background(), 999)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if order != nil {
+			t.Error("Expected nil order, got non-nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormOrderRepository_GetByTransactionID(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -84,17 +239,21 @@ func TestPostgresOrderRepository_GetByTransactionID(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresOrderRepository(db)
+	repo := NewGormOrderRepository(db)
 
 	t.Run("successful get by transaction id", func(t *testing.T) {
 		row := sqlmock.NewRows([]string{"id", "transaction_id", "total_amount", "status", "created_at", "updated_at"}).
-			AddRow(1, "TXN001", 99.99, "pending", time.Now(), time.Now())
+			AddRow(1, "TXN001", 9999, "pending", time.Now(), time.Now())
 
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE transaction_id = $1 ORDER BY "orders"."id" LIMIT $2`)).
 			WithArgs("TXN001", 1).
 			WillReturnRows(row)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_items" WHERE "order_items"."order_id" = $1`)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "price"}).
+				AddRow(1, 1, 10, "Product 1", 2, 1099))
 
-		order, err := repo.GetByTransactionID("TXN001")
+		order, err := repo.GetByTransactionID(context.Background(), "TXN001")
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -109,14 +268,18 @@ func TestPostgresOrderRepository_GetByTransactionID(t *testing.T) {
 			t.Errorf("Expected order transaction ID to be 'TXN001', got %s", order.TransactionID)
 		}
 
-		if order.TotalAmount != 99.99 {
-			t.Errorf("Expected order total amount to be 99.99, got %f", order.TotalAmount)
+		if order.TotalAmount != 9999 {
+			t.Errorf("Expected order total amount to be 9999, got %d", order.TotalAmount)
 		}
 
 		if order.Status != "pending" {
 			t.Errorf("Expected order status to be 'pending', got %s", order.Status)
 		}
 
+		if len(order.OrderItems) != 1 || order.OrderItems[0].Price != 1099 {
+			t.Errorf("Expected one item priced 1099, got %+v", order.OrderItems)
+		}
+
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("There were unfulfilled expectations: %s", err)
 		}
@@ -127,10 +290,10 @@ func TestPostgresOrderRepository_GetByTransactionID(t *testing.T) {
 			WithArgs("NONEXISTENT", 1).
 			WillReturnError(gorm.ErrRecordNotFound)
 
-		order, err := repo.GetByTransactionID("NONEXISTENT")
+		order, err := repo.GetByTransactionID(context.Background(), "NONEXISTENT")
 
-		if err != gorm.ErrRecordNotFound {
-			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
 		}
 
 		if order != nil {
@@ -143,7 +306,7 @@ func TestPostgresOrderRepository_GetByTransactionID(t *testing.T) {
 	})
 }
 
-func TestPostgresOrderRepository_Create(t *testing.T) {
+func TestGormOrderRepository_Create(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -153,22 +316,22 @@ func TestPostgresOrderRepository_Create(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresOrderRepository(db)
+	repo := NewGormOrderRepository(db)
 
 	t.Run("successful create", func(t *testing.T) {
 		order := &models.Order{
 			TransactionID: "TXN003",
-			TotalAmount:   199.99,
+			TotalAmount:   19999,
 			Status:        "pending",
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","total_amount","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`)).
-			WithArgs("TXN003", 199.99, "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","customer_id","total_amount","currency","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+			WithArgs("TXN003", "", 19999, "USD", "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		mock.ExpectCommit()
 
-		err := repo.Create(order)
+		err := repo.Create(context.Background(), order)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -186,17 +349,17 @@ func TestPostgresOrderRepository_Create(t *testing.T) {
 	t.Run("create error", func(t *testing.T) {
 		order := &models.Order{
 			TransactionID: "TXN004",
-			TotalAmount:   299.99,
+			TotalAmount:   29999,
 			Status:        "pending",
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","total_amount","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`)).
-			WithArgs("TXN004", 299.99, "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","customer_id","total_amount","currency","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+			WithArgs("TXN004", "", 29999, "USD", "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Create(order)
+		err := repo.Create(context.Background(), order)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -208,7 +371,7 @@ func TestPostgresOrderRepository_Create(t *testing.T) {
 	})
 }
 
-func TestPostgresOrderRepository_Update(t *testing.T) {
+func TestGormOrderRepository_Update(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -218,23 +381,24 @@ func TestPostgresOrderRepository_Update(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresOrderRepository(db)
+	repo := NewGormOrderRepository(db)
 
 	t.Run("successful update", func(t *testing.T) {
 		order := &models.Order{
 			ID:            1,
 			TransactionID: "TXN001",
-			TotalAmount:   149.99,
+			TotalAmount:   14999,
+			Currency:      "USD",
 			Status:        "confirmed",
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "transaction_id"=$1,"total_amount"=$2,"status"=$3,"created_at"=$4,"updated_at"=$5 WHERE "id" = $6`)).
-			WithArgs("TXN001", 149.99, "confirmed", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "transaction_id"=$1,"customer_id"=$2,"total_amount"=$3,"currency"=$4,"status"=$5,"updated_at"=$6 WHERE "id" = $7`)).
+			WithArgs("TXN001", "", 14999, "USD", "confirmed", sqlmock.AnyArg(), 1).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err := repo.Update(order)
+		err := repo.Update(context.Background(), order)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -245,21 +409,48 @@ func TestPostgresOrderRepository_Update(t *testing.T) {
 		}
 	})
 
+	t.Run("missing order", func(t *testing.T) {
+		order := &models.Order{
+			ID:            1,
+			TransactionID: "TXN001",
+			TotalAmount:   14999,
+			Currency:      "USD",
+			Status:        "confirmed",
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "transaction_id"=$1,"customer_id"=$2,"total_amount"=$3,"currency"=$4,"status"=$5,"updated_at"=$6 WHERE "id" = $7`)).
+			WithArgs("TXN001", "", 14999, "USD", "confirmed", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.Update(context.Background(), order)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
 	t.Run("update error", func(t *testing.T) {
 		order := &models.Order{
 			ID:            1,
 			TransactionID: "TXN001",
-			TotalAmount:   149.99,
+			TotalAmount:   14999,
+			Currency:      "USD",
 			Status:        "confirmed",
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "transaction_id"=$1,"total_amount"=$2,"status"=$3,"created_at"=$4,"updated_at"=$5 WHERE "id" = $6`)).
-			WithArgs("TXN001", 149.99, "confirmed", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "transaction_id"=$1,"customer_id"=$2,"total_amount"=$3,"currency"=$4,"status"=$5,"updated_at"=$6 WHERE "id" = $7`)).
+			WithArgs("TXN001", "", 14999, "USD", "confirmed", sqlmock.AnyArg(), 1).
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Update(order)
+		err := repo.Update(context.Background(), order)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -271,7 +462,7 @@ func TestPostgresOrderRepository_Update(t *testing.T) {
 	})
 }
 
-func TestPostgresOrderRepository_UpdateStatus(t *testing.T) {
+func TestGormOrderRepository_UpdateStatus(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -281,16 +472,16 @@ func TestPostgresOrderRepository_UpdateStatus(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresOrderRepository(db)
+	repo := NewGormOrderRepository(db)
 
 	t.Run("successful status update", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3`)).
-			WithArgs("shipped", sqlmock.AnyArg(), 1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs("shipped", sqlmock.AnyArg(), 1, "confirmed").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err := repo.UpdateStatus(1, "shipped")
+		err := repo.UpdateStatus(context.Background(), 1, "confirmed", "shipped")
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -303,12 +494,12 @@ func TestPostgresOrderRepository_UpdateStatus(t *testing.T) {
 
 	t.Run("status update error", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3`)).
-			WithArgs("delivered", sqlmock.AnyArg(), 2).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs("delivered", sqlmock.AnyArg(), 2, "shipped").
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.UpdateStatus(2, "delivered")
+		err := repo.UpdateStatus(context.Background(), 2, "shipped", "delivered")
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -319,17 +510,17 @@ func TestPostgresOrderRepository_UpdateStatus(t *testing.T) {
 		}
 	})
 
-	t.Run("update status for non-existent order", func(t *testing.T) {
+	t.Run("status changed concurrently", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3`)).
-			WithArgs("cancelled", sqlmock.AnyArg(), 999).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs("cancelled", sqlmock.AnyArg(), 999, "pending").
 			WillReturnResult(sqlmock.NewResult(1, 0)) // 0 rows affected
 		mock.ExpectCommit()
 
-		err := repo.UpdateStatus(999, "cancelled")
+		err := repo.UpdateStatus(context.Background(), 999, "pending", "cancelled")
 
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
+		if err != ErrOrderStatusConflict {
+			t.Errorf("Expected ErrOrderStatusConflict, got %v", err)
 		}
 
 		if err := mock.ExpectationsWereMet(); err != nil {
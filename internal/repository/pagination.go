@@ -0,0 +1,39 @@
+package repository
+
+import "math"
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxPage keeps page * page size, and so the offset, within an int.
+	MaxPage = math.MaxInt / MaxPageSize
+)
+
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+func (p Pagination) Offset() int {
+	if p.Page < 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.PageSize
+}
+
+// orderClause builds an ORDER BY clause from a whitelist of sortable columns.
+// The primary key is always the last sort key so pages are stable.
+func orderClause(columns map[string]string, sortBy string, desc bool) string {
+	column, ok := columns[sortBy]
+	if !ok {
+		column = "id"
+	}
+	order := column
+	if desc {
+		order += " DESC"
+	}
+	if column != "id" {
+		order += ", id"
+	}
+	return order
+}
@@ -1,50 +1,212 @@
 package repository
 
 import (
+	"context"
+	"strings"
+
+	"gorepositorytest/internal/apperror"
 	"gorepositorytest/internal/models"
 
 	"gorm.io/gorm"
 )
 
+// ProductListOptions filters, sorts and paginates ProductRepository.List.
+// Zero values disable the corresponding filter.
+type ProductListOptions struct {
+	Pagination
+	SortBy   string // price, name or created_at; defaults to id
+	SortDesc bool
+	MinPrice *models.Money
+	MaxPrice *models.Money
+	InStock  bool
+	Search   string // case-insensitive match on name
+}
+
+// ProductChanges lists the product fields to change. Nil fields keep their
+// stored value.
+type ProductChanges struct {
+	Name        *string
+	Description *string
+	Price       *models.Money
+	Currency    *string
+	Stock       *int
+}
+
+// columns maps the set fields to their columns.
+func (c ProductChanges) columns() map[string]any {
+	columns := map[string]any{}
+	if c.Name != nil {
+		columns["name"] = *c.Name
+	}
+	if c.Description != nil {
+		columns["description"] = *c.Description
+	}
+	if c.Price != nil {
+		columns["price"] = *c.Price
+	}
+	if c.Currency != nil {
+		columns["currency"] = *c.Currency
+	}
+	if c.Stock != nil {
+		columns["stock"] = *c.Stock
+	}
+	return columns
+}
+
+var productSortColumns = map[string]string{
+	"price":      "price",
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+func IsValidProductSort(field string) bool {
+	_, ok := productSortColumns[field]
+	return ok
+}
+
 type ProductRepository interface {
-	GetAll() ([]models.Product, error)
-	GetByID(id uint) (*models.Product, error)
-	Create(product *models.Product) error
-	Update(product *models.Product) error
-	Delete(id uint) error
+	GetAll(ctx context.Context) ([]models.Product, error)
+	List(ctx context.Context, opts ProductListOptions) ([]models.Product, int64, error)
+	GetByID(ctx context.Context, id uint) (*models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+	Update(ctx context.Context, product *models.Product) error
+	Patch(ctx context.Context, id uint, changes ProductChanges) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	DecrementStock(ctx context.Context, id uint, quantity int) error
+	IncrementStock(ctx context.Context, id uint, quantity int) error
 }
 
-type postgresProductRepository struct {
+type gormProductRepository struct {
 	db *gorm.DB
 }
 
-func NewPostgresProductRepository(db *gorm.DB) ProductRepository {
-	return &postgresProductRepository{db: db}
+func NewGormProductRepository(db *gorm.DB) ProductRepository {
+	return &gormProductRepository{db: db}
 }
 
-func (r *postgresProductRepository) GetAll() ([]models.Product, error) {
+func (r *gormProductRepository) GetAll(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Find(&products).Error
+	err := r.db.WithContext(ctx).Find(&products).Error
 	return products, err
 }
 
-func (r *postgresProductRepository) GetByID(id uint) (*models.Product, error) {
+// List returns one page of products matching opts together with the total
+// number of matching products.
+func (r *gormProductRepository) List(ctx context.Context, opts ProductListOptions) ([]models.Product, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Product{})
+	if opts.MinPrice != nil {
+		query = query.Where("price >= ?", *opts.MinPrice)
+	}
+	if opts.MaxPrice != nil {
+		query = query.Where("price <= ?", *opts.MaxPrice)
+	}
+	if opts.InStock {
+		query = query.Where("stock > ?", 0)
+	}
+	if opts.Search != "" {
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(opts.Search))+"%")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order(orderClause(productSortColumns, opts.SortBy, opts.SortDesc))
+	if opts.PageSize > 0 {
+		query = query.Limit(opts.PageSize).Offset(opts.Offset())
+	}
+
+	var products []models.Product
+	if err := query.Find(&products).Error; err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+func (r *gormProductRepository) GetByID(ctx context.Context, id uint) (*models.Product, error) {
 	var product models.Product
-	err := r.db.First(&product, id).Error
+	err := r.db.WithContext(ctx).First(&product, id).Error
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "Product not found")
 	}
 	return &product, nil
 }
 
-func (r *postgresProductRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+func (r *gormProductRepository) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Create(product).Error
+}
+
+// Update saves every field of a product in the catalog. Unlike Save it never
+// inserts, so it cannot bring back a product archived since it was read.
+func (r *gormProductRepository) Update(ctx context.Context, product *models.Product) error {
+	result := r.db.WithContext(ctx).Model(product).
+		Select("*").Omit("created_at", "deleted_at").
+		Updates(product)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NotFound("Product not found")
+	}
+	return nil
+}
+
+// Patch writes only the changed columns, so it cannot undo a concurrent
+// stock change it was not asked to make.
+func (r *gormProductRepository) Patch(ctx context.Context, id uint, changes ProductChanges) error {
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ?", id).
+		Updates(changes.columns())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NotFound("Product not found")
+	}
+	return nil
+}
+
+// Delete archives the product; the row stays for past orders.
+func (r *gormProductRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Product{}, id).Error
 }
 
-func (r *postgresProductRepository) Update(product *models.Product) error {
-	return r.db.Save(product).Error
+// Restore brings an archived product back into the catalog.
+func (r *gormProductRepository) Restore(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NotFound("Archived product not found")
+	}
+	return nil
+}
+
+// DecrementStock only succeeds when enough stock is left, so concurrent
+// checkouts cannot drive the stock below zero.
+func (r *gormProductRepository) DecrementStock(ctx context.Context, id uint, quantity int) error {
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", id, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
 }
 
-func (r *postgresProductRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Product{}, id).Error
+// IncrementStock also restocks archived products, so a cancelled order
+// returns its items even if the product was archived in the meantime.
+func (r *gormProductRepository) IncrementStock(ctx context.Context, id uint, quantity int) error {
+	return r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("id = ?", id).
+		Update("stock", gorm.Expr("stock + ?", quantity)).Error
 }
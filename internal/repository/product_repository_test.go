@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"gorepositorytest/internal/apperror"
 	"gorepositorytest/internal/models"
 	"regexp"
 	"testing"
@@ -32,7 +34,7 @@ func setupTestDB() (*gorm.DB, sqlmock.Sqlmock, error) {
 	return gormDB, mock, nil
 }
 
-func TestPostgresProductRepository_GetAll(t *testing.T) {
+func TestGormProductRepository_GetAll(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -42,17 +44,17 @@ func TestPostgresProductRepository_GetAll(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresProductRepository(db)
+	repo := NewGormProductRepository(db)
 
 	t.Run("successful get all products", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "description", "price", "stock", "created_at", "updated_at"}).
-			AddRow(1, "Product 1", "Description 1", 10.99, 100, time.Now(), time.Now()).
-			AddRow(2, "Product 2", "Description 2", 15.99, 50, time.Now(), time.Now())
+			AddRow(1, "Product 1", "Description 1", 1099, 100, time.Now(), time.Now()).
+			AddRow(2, "Product 2", "Description 2", 1599, 50, time.Now(), time.Now())
 
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products"`)).
 			WillReturnRows(rows)
 
-		products, err := repo.GetAll()
+		products, err := repo.GetAll(context.Background())
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -75,7 +77,7 @@ func TestPostgresProductRepository_GetAll(t *testing.T) {
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products"`)).
 			WillReturnError(sql.ErrConnDone)
 
-		products, err := repo.GetAll()
+		products, err := repo.GetAll(context.Background())
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -91,7 +93,7 @@ func TestPostgresProductRepository_GetAll(t *testing.T) {
 	})
 }
 
-func TestPostgresProductRepository_GetByID(t *testing.T) {
+func TestGormProductRepository_GetByID(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -101,17 +103,17 @@ func TestPostgresProductRepository_GetByID(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresProductRepository(db)
+	repo := NewGormProductRepository(db)
 
 	t.Run("successful get by id", func(t *testing.T) {
 		row := sqlmock.NewRows([]string{"id", "name", "description", "price", "stock", "created_at", "updated_at"}).
-			AddRow(1, "Product 1", "Description 1", 10.99, 100, time.Now(), time.Now())
+			AddRow(1, "Product 1", "Description 1", 1099, 100, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" = $1 ORDER BY "products"."id" LIMIT $2`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" = $1 AND "products"."deleted_at" IS NULL ORDER BY "products"."id" LIMIT $2`)).
 			WithArgs(1, 1).
 			WillReturnRows(row)
 
-		product, err := repo.GetByID(1)
+		product, err := repo.GetByID(context.Background(), 1)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -136,14 +138,14 @@ func TestPostgresProductRepository_GetByID(t *testing.T) {
 	})
 
 	t.Run("product not found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" = $1 ORDER BY "products"."id" LIMIT $2`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" = $1 AND "products"."deleted_at" IS NULL ORDER BY "products"."id" LIMIT $2`)).
 			WithArgs(999, 1).
 			WillReturnError(gorm.ErrRecordNotFound)
 
-		product, err := repo.GetByID(999)
+		product, err := repo.GetByID(context.Background(), 999)
 
-		if err != gorm.ErrRecordNotFound {
-			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
 		}
 
 		if product != nil {
@@ -154,9 +156,25 @@ func TestPostgresProductRepository_GetByID(t *testing.T) {
 			t.Errorf("There were unfulfilled expectations: %s", err)
 		}
 	})
+	t.Run("query outlives the deadline", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" = $1 AND "products"."deleted_at" IS NULL ORDER BY "products"."id" LIMIT $2`)).
+			WithArgs(1, 1).
+			WillDelayFor(time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := repo.GetByID(ctx, 1)
+
+		if err == nil || time.Since(start) >= time.Second {
+			t.Errorf("Expected the query to be cancelled, got %v after %v", err, time.Since(start))
+		}
+	})
 }
 
-func TestPostgresProductRepository_Create(t *testing.T) {
+func TestGormProductRepository_Create(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -166,23 +184,23 @@ func TestPostgresProductRepository_Create(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresProductRepository(db)
+	repo := NewGormProductRepository(db)
 
 	t.Run("successful create", func(t *testing.T) {
 		product := &models.Product{
 			Name:        "New Product",
 			Description: "New Description",
-			Price:       25.99,
+			Price:       2599,
 			Stock:       75,
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "products" ("name","description","price","stock","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`)).
-			WithArgs("New Product", "New Description", 25.99, 75, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "products" ("name","description","price","currency","stock","created_at","updated_at","deleted_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+			WithArgs("New Product", "New Description", 2599, "USD", 75, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		mock.ExpectCommit()
 
-		err := repo.Create(product)
+		err := repo.Create(context.Background(), product)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -201,17 +219,17 @@ func TestPostgresProductRepository_Create(t *testing.T) {
 		product := &models.Product{
 			Name:        "New Product",
 			Description: "New Description",
-			Price:       25.99,
+			Price:       2599,
 			Stock:       75,
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "products" ("name","description","price","stock","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`)).
-			WithArgs("New Product", "New Description", 25.99, 75, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "products" ("name","description","price","currency","stock","created_at","updated_at","deleted_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+			WithArgs("New Product", "New Description", 2599, "USD", 75, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Create(product)
+		err := repo.Create(context.Background(), product)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -223,7 +241,7 @@ func TestPostgresProductRepository_Create(t *testing.T) {
 	})
 }
 
-func TestPostgresProductRepository_Update(t *testing.T) {
+func TestGormProductRepository_Update(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -233,24 +251,25 @@ func TestPostgresProductRepository_Update(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresProductRepository(db)
+	repo := NewGormProductRepository(db)
 
 	t.Run("successful update", func(t *testing.T) {
 		product := &models.Product{
 			ID:          1,
 			Name:        "Updated Product",
 			Description: "Updated Description",
-			Price:       35.99,
+			Price:       3599,
+			Currency:    "USD",
 			Stock:       25,
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"description"=$2,"price"=$3,"stock"=$4,"created_at"=$5,"updated_at"=$6 WHERE "id" = $7`)).
-			WithArgs("Updated Product", "Updated Description", 35.99, 25, sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"description"=$2,"price"=$3,"currency"=$4,"stock"=$5,"updated_at"=$6 WHERE "products"."deleted_at" IS NULL AND "id" = $7`)).
+			WithArgs("Updated Product", "Updated Description", 3599, "USD", 25, sqlmock.AnyArg(), 1).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err := repo.Update(product)
+		err := repo.Update(context.Background(), product)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -261,22 +280,50 @@ func TestPostgresProductRepository_Update(t *testing.T) {
 		}
 	})
 
+	t.Run("missing or archived product", func(t *testing.T) {
+		product := &models.Product{
+			ID:          1,
+			Name:        "Updated Product",
+			Description: "Updated Description",
+			Price:       3599,
+			Currency:    "USD",
+			Stock:       25,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"description"=$2,"price"=$3,"currency"=$4,"stock"=$5,"updated_at"=$6 WHERE "products"."deleted_at" IS NULL AND "id" = $7`)).
+			WithArgs("Updated Product", "Updated Description", 3599, "USD", 25, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.Update(context.Background(), product)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
 	t.Run("update error", func(t *testing.T) {
 		product := &models.Product{
 			ID:          1,
 			Name:        "Updated Product",
 			Description: "Updated Description",
-			Price:       35.99,
+			Price:       3599,
+			Currency:    "USD",
 			Stock:       25,
 		}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"description"=$2,"price"=$3,"stock"=$4,"created_at"=$5,"updated_at"=$6 WHERE "id" = $7`)).
-			WithArgs("Updated Product", "Updated Description", 35.99, 25, sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"description"=$2,"price"=$3,"currency"=$4,"stock"=$5,"updated_at"=$6 WHERE "products"."deleted_at" IS NULL AND "id" = $7`)).
+			WithArgs("Updated Product", "Updated Description", 3599, "USD", 25, sqlmock.AnyArg(), 1).
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Update(product)
+		err := repo.Update(context.Background(), product)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -288,7 +335,7 @@ func TestPostgresProductRepository_Update(t *testing.T) {
 	})
 }
 
-func TestPostgresProductRepository_Delete(t *testing.T) {
+func TestGormProductRepository_Patch(t *testing.T) {
 	db, mock, err := setupTestDB()
 	if err != nil {
 		t.Fatalf("Failed to setup test database: %v", err)
@@ -298,16 +345,66 @@ func TestPostgresProductRepository_Delete(t *testing.T) {
 		sqlDB.Close()
 	}()
 
-	repo := NewPostgresProductRepository(db)
+	repo := NewGormProductRepository(db)
+	name := "Renamed"
+
+	t.Run("writes only the changed columns", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"updated_at"=$2 WHERE id = $3 AND "products"."deleted_at" IS NULL`)).
+			WithArgs("Renamed", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Patch(context.Background(), 1, ProductChanges{Name: &name})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("missing or archived product", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "name"=$1,"updated_at"=$2 WHERE id = $3 AND "products"."deleted_at" IS NULL`)).
+			WithArgs("Renamed", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.Patch(context.Background(), 1, ProductChanges{Name: &name})
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormProductRepository_Delete(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormProductRepository(db)
 
 	t.Run("successful delete", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "products" WHERE "products"."id" = $1`)).
-			WithArgs(1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1 WHERE "products"."id" = $2 AND "products"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 1).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err := repo.Delete(1)
+		err := repo.Delete(context.Background(), 1)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -320,12 +417,12 @@ func TestPostgresProductRepository_Delete(t *testing.T) {
 
 	t.Run("delete error", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "products" WHERE "products"."id" = $1`)).
-			WithArgs(1).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1 WHERE "products"."id" = $2 AND "products"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 1).
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Delete(1)
+		err := repo.Delete(context.Background(), 1)
 
 		if err == nil {
 			t.Error("Expected error, got nil")
@@ -338,12 +435,12 @@ func TestPostgresProductRepository_Delete(t *testing.T) {
 
 	t.Run("delete non-existent product", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "products" WHERE "products"."id" = $1`)).
-			WithArgs(999).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1 WHERE "products"."id" = $2 AND "products"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 999).
 			WillReturnResult(sqlmock.NewResult(1, 0)) // 0 rows affected
 		mock.ExpectCommit()
 
-		err := repo.Delete(999)
+		err := repo.Delete(context.Background(), 999)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -354,3 +451,253 @@ func TestPostgresProductRepository_Delete(t *testing.T) {
 		}
 	})
 }
+
+func TestGormProductRepository_Restore(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormProductRepository(db)
+
+	t.Run("successful restore", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1,"updated_at"=$2 WHERE id = $3 AND deleted_at IS NOT NULL`)).
+			WithArgs(nil, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.Restore(context.Background(), 1)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("product not archived", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1,"updated_at"=$2 WHERE id = $3 AND deleted_at IS NOT NULL`)).
+			WithArgs(nil, sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(1, 0))
+		mock.ExpectCommit()
+
+		err := repo.Restore(context.Background(), 2)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormProductRepository_DecrementStock(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormProductRepository(db)
+
+	t.Run("successful decrement", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(3, sqlmock.AnyArg(), 1, 3).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.DecrementStock(context.Background(), 1, 3)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("insufficient stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(10, sqlmock.AnyArg(), 1, 10).
+			WillReturnResult(sqlmock.NewResult(1, 0)) // 0 rows affected
+		mock.ExpectCommit()
+
+		err := repo.DecrementStock(context.Background(), 1, 10)
+
+		if err != ErrInsufficientStock {
+			t.Errorf("Expected ErrInsufficientStock, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("decrement error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(1, sqlmock.AnyArg(), 1, 1).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err := repo.DecrementStock(context.Background(), 1, 1)
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormProductRepository_IncrementStock(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormProductRepository(db)
+
+	t.Run("successful increment", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock + $1,"updated_at"=$2 WHERE id = $3`)).
+			WithArgs(3, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.IncrementStock(context.Background(), 1, 3)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("increment error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock + $1,"updated_at"=$2 WHERE id = $3`)).
+			WithArgs(3, sqlmock.AnyArg(), 1).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err := repo.IncrementStock(context.Background(), 1, 3)
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGormProductRepository_List(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	repo := NewGormProductRepository(db)
+
+	t.Run("filters, sorts and paginates", func(t *testing.T) {
+		minPrice := models.Money(1000)
+		maxPrice := models.Money(5000)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "products" WHERE price >= $1 AND price <= $2 AND stock > $3 AND LOWER(name) LIKE $4 ESCAPE '\' AND "products"."deleted_at" IS NULL`)).
+			WithArgs(1000, 5000, 0, "%widget%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(25))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE price >= $1 AND price <= $2 AND stock > $3 AND LOWER(name) LIKE $4 ESCAPE '\' AND "products"."deleted_at" IS NULL ORDER BY price DESC, id LIMIT $5 OFFSET $6`)).
+			WithArgs(1000, 5000, 0, "%widget%", 10, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "stock"}).
+				AddRow(11, "Blue Widget", 4999, 3))
+
+		products, total, err := repo.List(context.Background(), ProductListOptions{
+			Pagination: Pagination{Page: 2, PageSize: 10},
+			SortBy:     "price",
+			SortDesc:   true,
+			MinPrice:   &minPrice,
+			MaxPrice:   &maxPrice,
+			InStock:    true,
+			Search:     "Widget",
+		})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if total != 25 {
+			t.Errorf("Expected total 25, got %d", total)
+		}
+
+		if len(products) != 1 || products[0].Name != "Blue Widget" {
+			t.Errorf("Expected 'Blue Widget', got %+v", products)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("defaults to ordering by id", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "products" WHERE "products"."deleted_at" IS NULL`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."deleted_at" IS NULL ORDER BY id LIMIT $1`)).
+			WithArgs(20).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, _, err := repo.List(context.Background(), ProductListOptions{Pagination: Pagination{Page: 1, PageSize: 20}})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("count error", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "products" WHERE "products"."deleted_at" IS NULL`)).
+			WillReturnError(sql.ErrConnDone)
+
+		_, _, err := repo.List(context.Background(), ProductListOptions{Pagination: Pagination{Page: 1, PageSize: 20}})
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
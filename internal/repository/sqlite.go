@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqlitePragmas are applied to every connection: SQLite leaves foreign keys
+// unchecked by default, and Postgres matches LIKE patterns case-sensitively.
+var sqlitePragmas = []string{"foreign_keys(1)", "case_sensitive_like(1)"}
+
+// OpenSQLite opens the SQLite database at path, or a private in-memory
+// database for ":memory:". The GORM repositories, unit of work and
+// idempotency store only use SQL that Postgres and SQLite both understand,
+// so they run on the returned database unchanged.
+//
+// SQLite allows one writer at a time, so the pool is held to a single
+// connection that is never closed; for ":memory:" closing it would lose
+// the data.
+func OpenSQLite(path string, config *gorm.Config) (*gorm.DB, error) {
+	dsn := path
+	for i, pragma := range sqlitePragmas {
+		sep := "&"
+		if i == 0 && !strings.Contains(dsn, "?") {
+			sep = "?"
+		}
+		dsn += sep + "_pragma=" + pragma
+	}
+
+	// Times are stored as text, which only sorts and compares correctly when
+	// every value has the same offset.
+	if config.NowFunc == nil {
+		config.NowFunc = func() time.Time { return time.Now().UTC() }
+	}
+
+	db, err := gorm.Open(sqlite.Open(dsn), config)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	return db, nil
+}
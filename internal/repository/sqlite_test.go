@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"gorepositorytest/internal/migrations"
+	"gorepositorytest/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := OpenSQLite(":memory:", &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("enforces foreign keys", func(t *testing.T) {
+		orders := NewGormOrderRepository(setupSQLite(t))
+
+		err := orders.Create(ctx, &models.Order{
+			TransactionID: "TX_1",
+			OrderItems:    []models.OrderItem{{ProductID: 42, Quantity: 1, Price: 100}},
+		})
+		if err == nil {
+			t.Error("Expected an item for a missing product to be rejected")
+		}
+	})
+
+	t.Run("reserves idempotency keys once", func(t *testing.T) {
+		repo := NewGormIdempotencyRepository(setupSQLite(t))
+
+		record := &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "hash"}
+		if existing, err := repo.Reserve(ctx, record); err != nil || existing != nil {
+			t.Fatalf("Expected the key to be reserved, got %+v, %v", existing, err)
+		}
+		existing, err := repo.Reserve(ctx, &models.IdempotencyRecord{Scope: "user-1", Key: "key-1", RequestHash: "other"})
+		if err != nil || existing == nil || existing.RequestHash != "hash" {
+			t.Errorf("Expected the first reservation back, got %+v, %v", existing, err)
+		}
+	})
+}
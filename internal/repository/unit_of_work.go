@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gorepositorytest/internal/apperror"
+
+	"gorm.io/gorm"
+)
+
+var ErrInsufficientStock = apperror.InsufficientStock("Insufficient stock")
+
+type Repositories struct {
+	Products ProductRepository
+	Orders   OrderRepository
+}
+
+// UnitOfWork runs fn against repositories bound to a single transaction.
+// If fn returns an error every change made through repos is rolled back.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
+
+type gormUnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewGormUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &gormUnitOfWork{db: db}
+}
+
+func (u *gormUnitOfWork) Do(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(Repositories{
+			Products: NewGormProductRepository(tx),
+			Orders:   NewGormOrderRepository(tx),
+		})
+	})
+}
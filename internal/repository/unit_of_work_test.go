@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gorepositorytest/internal/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGormUnitOfWork_Do(t *testing.T) {
+	db, mock, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	uow := NewGormUnitOfWork(db)
+	ctx := context.Background()
+
+	t.Run("commits stock decrement and order insert together", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(2, sqlmock.AnyArg(), 1, 2).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","customer_id","total_amount","currency","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+			WithArgs("TXN001", "", 2198, "USD", "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		err := uow.Do(ctx, func(repos Repositories) error {
+			if err := repos.Products.DecrementStock(ctx, 1, 2); err != nil {
+				return err
+			}
+			return repos.Orders.Create(ctx, &models.Order{TransactionID: "TXN001", TotalAmount: 2198, Status: "pending"})
+		})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("rolls back when stock is insufficient", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(1, sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(5, sqlmock.AnyArg(), 2, 5).
+			WillReturnResult(sqlmock.NewResult(1, 0))
+		mock.ExpectRollback()
+
+		err := uow.Do(ctx, func(repos Repositories) error {
+			if err := repos.Products.DecrementStock(ctx, 1, 1); err != nil {
+				return err
+			}
+			return repos.Products.DecrementStock(ctx, 2, 5)
+		})
+
+		if err != ErrInsufficientStock {
+			t.Errorf("Expected ErrInsufficientStock, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("rolls back when order insert fails", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "stock"=stock - $1,"updated_at"=$2 WHERE (id = $3 AND stock >= $4) AND "products"."deleted_at" IS NULL`)).
+			WithArgs(1, sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("transaction_id","customer_id","total_amount","currency","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+			WithArgs("TXN002", "", 1099, "USD", "pending", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err := uow.Do(ctx, func(repos Repositories) error {
+			if err := repos.Products.DecrementStock(ctx, 1, 1); err != nil {
+				return err
+			}
+			return repos.Orders.Create(ctx, &models.Order{TransactionID: "TXN002", TotalAmount: 1099, Status: "pending"})
+		})
+
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
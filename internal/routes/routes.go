@@ -2,21 +2,32 @@ package routes
 
 import (
 	"gorepositorytest/internal/handler"
+	"gorepositorytest/internal/metrics"
 	"gorepositorytest/internal/middleware"
-	"gorepositorytest/internal/repository"
+	"gorepositorytest/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupProductRoutes(r *gin.Engine, productRepo repository.ProductRepository) {
-	r.GET("/products", middleware.Authenticate(), handler.GetAllProducts(productRepo))
-	r.POST("/products", middleware.Authenticate(), handler.AddProduct(productRepo))
-	r.DELETE("/products/:id", middleware.Authenticate(), handler.DeleteProduct(productRepo))
+func SetupProductRoutes(r *gin.Engine, authCfg middleware.AuthConfig, products service.ProductService) {
+	r.GET("/products", middleware.Authenticate(authCfg), handler.GetAllProducts(products))
+	r.GET("/products/:id", middleware.Authenticate(authCfg), handler.GetProductByID(products))
+	r.POST("/products", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleAdmin), handler.AddProduct(products))
+	r.PUT("/products/:id", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleAdmin), handler.UpdateProduct(products))
+	r.PATCH("/products/:id", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleAdmin), handler.PatchProduct(products))
+	r.DELETE("/products/:id", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleAdmin), handler.DeleteProduct(products))
+	r.POST("/products/:id/restore", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleAdmin), handler.RestoreProduct(products))
 }
 
-func SetupOrderRoutes(r *gin.Engine, orderRepo repository.OrderRepository, productRepo repository.ProductRepository) {
-	r.GET("/orders", middleware.Authenticate(), handler.GetAllOrders(orderRepo))
-	r.GET("/orders/transaction/:transactionId", middleware.Authenticate(), handler.GetOrderByTransactionID(orderRepo))
-	r.POST("/orders", middleware.Authenticate(), handler.CreateOrder(orderRepo, productRepo))
-	r.PUT("/orders/:id/status", middleware.Authenticate(), handler.UpdateOrderStatus(orderRepo))
+func SetupOrderRoutes(r *gin.Engine, authCfg middleware.AuthConfig, idempotencyCfg middleware.IdempotencyConfig, orders service.OrderService) {
+	r.GET("/orders", middleware.Authenticate(authCfg), handler.GetAllOrders(orders))
+	r.GET("/orders/transaction/:transactionId", middleware.Authenticate(authCfg), handler.GetOrderByTransactionID(orders))
+	r.POST("/orders", middleware.Authenticate(authCfg), middleware.Idempotency(idempotencyCfg), handler.CreateOrder(orders))
+	r.PUT("/orders/:id/status", middleware.Authenticate(authCfg), middleware.RequireRole(middleware.RoleStaff, middleware.RoleAdmin), handler.UpdateOrderStatus(orders))
+}
+
+// SetupMetricsRoutes serves the Prometheus metrics. The endpoint is not
+// authenticated, so keep it off the public network.
+func SetupMetricsRoutes(r *gin.Engine, m *metrics.Metrics) {
+	r.GET("/metrics", gin.WrapH(m.Handler()))
 }
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Actor is the caller a service method acts for. Staff may work with every
+// customer's orders; other callers only with their own.
+type Actor struct {
+	ID    string
+	Staff bool
+}
+
+type OrderItemInput struct {
+	ProductID uint
+	Quantity  int
+}
+
+type OrderService interface {
+	List(ctx context.Context, actor Actor, opts repository.OrderListOptions) ([]models.Order, int64, error)
+	GetByTransactionID(ctx context.Context, actor Actor, transactionID string) (*models.Order, error)
+	Place(ctx context.Context, actor Actor, items []OrderItemInput) (*models.Order, error)
+	UpdateStatus(ctx context.Context, orderID uint, status string) error
+}
+
+// OrderMetrics counts what happens to orders. It is only told about
+// changes that were committed.
+type OrderMetrics interface {
+	OrderPlaced(order *models.Order)
+	OrderStatusChanged(from, to string)
+	StockOutRejected()
+}
+
+type noOrderMetrics struct{}
+
+func (noOrderMetrics) OrderPlaced(*models.Order)          {}
+func (noOrderMetrics) OrderStatusChanged(from, to string) {}
+func (noOrderMetrics) StockOutRejected()                  {}
+
+type orderService struct {
+	orders  repository.OrderRepository
+	uow     repository.UnitOfWork
+	metrics OrderMetrics
+}
+
+// NewOrderService returns an OrderService reporting to metrics, which may be
+// nil.
+func NewOrderService(orders repository.OrderRepository, uow repository.UnitOfWork, metrics OrderMetrics) OrderService {
+	if metrics == nil {
+		metrics = noOrderMetrics{}
+	}
+	return &orderService{orders: orders, uow: uow, metrics: metrics}
+}
+
+func generateTransactionID() string {
+	return uuid.New().String()
+}
+
+func (s *orderService) List(ctx context.Context, actor Actor, opts repository.OrderListOptions) ([]models.Order, int64, error) {
+	if !actor.Staff {
+		opts.CustomerID = actor.ID
+	}
+	return s.orders.List(ctx, opts)
+}
+
+func (s *orderService) GetByTransactionID(ctx context.Context, actor Actor, transactionID string) (*models.Order, error) {
+	if transactionID == "" {
+		return nil, apperror.BadRequest("Transaction ID is required")
+	}
+
+	order, err := s.orders.GetByTransactionID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other customers' orders are reported as missing so their transaction
+	// IDs cannot be probed.
+	if order.CustomerID != actor.ID && !actor.Staff {
+		return nil, apperror.NotFound("Order not found")
+	}
+	return order, nil
+}
+
+// Place reserves stock for every item and records the order in one
+// transaction, so either the whole order is placed or nothing changes.
+func (s *orderService) Place(ctx context.Context, actor Actor, items []OrderItemInput) (*models.Order, error) {
+	if err := validateOrderItems(items); err != nil {
+		return nil, err
+	}
+
+	var order *models.Order
+	err := s.uow.Do(ctx, func(repos repository.Repositories) error {
+		var totalAmount models.Money
+		var currency string
+		var orderItems []models.OrderItem
+
+		for _, item := range items {
+			product, err := repos.Products.GetByID(ctx, item.ProductID)
+			if err != nil {
+				if apperror.CodeOf(err) == apperror.CodeNotFound {
+					return apperror.BadRequest("Product not found: " + strconv.Itoa(int(item.ProductID)))
+				}
+				return err
+			}
+
+			if currency == "" {
+				currency = product.Currency
+			} else if product.Currency != currency {
+				return apperror.BadRequest("All products in an order must use the same currency")
+			}
+
+			subtotal, ok := product.Price.Mul(int64(item.Quantity))
+			if ok {
+				totalAmount, ok = totalAmount.Add(subtotal)
+			}
+			if !ok {
+				return apperror.BadRequest("Order total is too large")
+			}
+
+			if err := repos.Products.DecrementStock(ctx, item.ProductID, item.Quantity); err != nil {
+				if errors.Is(err, repository.ErrInsufficientStock) {
+					return apperror.InsufficientStock("Insufficient stock for product: " + product.Name)
+				}
+				return err
+			}
+
+			orderItems = append(orderItems, models.OrderItem{
+				ProductID:   item.ProductID,
+				ProductName: product.Name,
+				Quantity:    item.Quantity,
+				Price:       product.Price,
+			})
+		}
+
+		order = &models.Order{
+			TransactionID: generateTransactionID(),
+			CustomerID:    actor.ID,
+			OrderItems:    orderItems,
+			TotalAmount:   totalAmount,
+			Currency:      currency,
+			Status:        models.OrderStatusPending,
+		}
+
+		return repos.Orders.Create(ctx, order)
+	})
+	if err != nil {
+		if apperror.CodeOf(err) == apperror.CodeInsufficientStock {
+			s.metrics.StockOutRejected()
+		}
+		return nil, err
+	}
+	s.metrics.OrderPlaced(order)
+	return order, nil
+}
+
+// UpdateStatus moves the order along its lifecycle. Cancelling an order
+// returns its items to stock; repeating a cancellation is a no-op so the
+// stock is only returned once.
+func (s *orderService) UpdateStatus(ctx context.Context, orderID uint, status string) error {
+	if !models.IsValidOrderStatus(status) {
+		return apperror.Validation("Validation failed", []apperror.FieldError{
+			{Field: "status", Rule: "oneof", Message: "status is not a known order status"},
+		})
+	}
+
+	var from string
+	err := s.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if order.Status == models.OrderStatusCancelled && status == models.OrderStatusCancelled {
+			return nil
+		}
+		from = order.Status
+
+		if !models.CanTransitionOrderStatus(order.Status, status) {
+			return apperror.Conflict("Cannot change order status from " + order.Status + " to " + status)
+		}
+
+		if err := repos.Orders.UpdateStatus(ctx, order.ID, order.Status, status); err != nil {
+			return err
+		}
+
+		if status == models.OrderStatusCancelled {
+			for _, item := range order.OrderItems {
+				if err := repos.Products.IncrementStock(ctx, item.ProductID, item.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil && from != "" {
+		s.metrics.OrderStatusChanged(from, status)
+	}
+	return err
+}
+
+func validateOrderItems(items []OrderItemInput) error {
+	var fields []apperror.FieldError
+	if len(items) == 0 {
+		fields = append(fields, apperror.FieldError{Field: "order_items", Rule: "min", Message: "order_items must contain at least 1 items"})
+	}
+	for i, item := range items {
+		if item.ProductID == 0 {
+			field := fmt.Sprintf("order_items[%d].product_id", i)
+			fields = append(fields, apperror.FieldError{Field: field, Rule: "required", Message: field + " is required"})
+		}
+		if item.Quantity < 1 {
+			field := fmt.Sprintf("order_items[%d].quantity", i)
+			fields = append(fields, apperror.FieldError{Field: field, Rule: "min", Message: field + " must be at least 1"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return apperror.Validation("Validation failed", fields)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+)
+
+// failingStockRepository fails every DecrementStock with err.
+type failingStockRepository struct {
+	repository.ProductRepository
+	err error
+}
+
+func (r failingStockRepository) DecrementStock(ctx context.Context, id uint, quantity int) error {
+	return r.err
+}
+
+// failingStockUnitOfWork hands fn a product repository whose stock updates
+// fail with err.
+type failingStockUnitOfWork struct {
+	repository.UnitOfWork
+	err error
+}
+
+func (u failingStockUnitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	return u.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		repos.Products = failingStockRepository{ProductRepository: repos.Products, err: u.err}
+		return fn(repos)
+	})
+}
+
+type testOrderStore struct {
+	store    *repository.MemoryStore
+	orders   repository.OrderRepository
+	products repository.ProductRepository
+}
+
+func newTestOrderService(t *testing.T, orders []models.Order, products []models.Product) (OrderService, testOrderStore) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	ts := testOrderStore{
+		store:    store,
+		orders:   repository.NewMemoryOrderRepository(store),
+		products: newTestProductRepository(t, store, products...),
+	}
+	for _, order := range orders {
+		if err := ts.orders.Create(context.Background(), &order); err != nil {
+			t.Fatalf("Failed to create order: %v", err)
+		}
+	}
+	return NewOrderService(ts.orders, repository.NewMemoryUnitOfWork(store), nil), ts
+}
+
+func (ts testOrderStore) stock(t *testing.T, id uint) int {
+	t.Helper()
+	product, err := ts.products.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to read product %d: %v", id, err)
+	}
+	return product.Stock
+}
+
+func (ts testOrderStore) orderCount(t *testing.T) int {
+	t.Helper()
+	orders, err := ts.orders.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read orders: %v", err)
+	}
+	return len(orders)
+}
+
+func (ts testOrderStore) status(t *testing.T, id uint) string {
+	t.Helper()
+	order, err := ts.orders.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to read order %d: %v", id, err)
+	}
+	return order.Status
+}
+
+var customer = Actor{ID: "user-1"}
+
+func TestOrderService_Place(t *testing.T) {
+	t.Run("prices the order and reserves stock", func(t *testing.T) {
+		svc, ts := newTestOrderService(t, nil, []models.Product{
+			{ID: 1, Name: "Widget", Price: 1099, Currency: "EUR", Stock: 10},
+			{ID: 2, Name: "Gadget", Price: 250, Currency: "EUR", Stock: 3},
+		})
+
+		order, err := svc.Place(context.Background(), customer, []OrderItemInput{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 3}})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if order.TotalAmount != 2948 || order.Currency != "EUR" {
+			t.Errorf("Expected total 2948 EUR, got %d %s", order.TotalAmount, order.Currency)
+		}
+
+		if order.CustomerID != "user-1" || order.Status != models.OrderStatusPending || order.TransactionID == "" {
+			t.Errorf("Expected pending order for user-1 with a transaction ID, got %+v", order)
+		}
+
+		if len(order.OrderItems) != 2 || order.OrderItems[1].ProductName != "Gadget" || order.OrderItems[1].Price != 250 {
+			t.Errorf("Expected item snapshots, got %+v", order.OrderItems)
+		}
+
+		if ts.stock(t, 1) != 8 || ts.stock(t, 2) != 0 {
+			t.Errorf("Expected stock 8 and 0, got %d and %d", ts.stock(t, 1), ts.stock(t, 2))
+		}
+
+		if ts.orderCount(t) != 1 {
+			t.Errorf("Expected 1 stored order, got %d", ts.orderCount(t))
+		}
+	})
+
+	t.Run("insufficient stock rolls back the whole order", func(t *testing.T) {
+		svc, ts := newTestOrderService(t, nil, []models.Product{
+			{ID: 1, Name: "Widget", Price: 1099, Currency: "USD", Stock: 10},
+			{ID: 2, Name: "Gadget", Price: 250, Currency: "USD", Stock: 1},
+		})
+
+		_, err := svc.Place(context.Background(), customer, []OrderItemInput{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 3}})
+
+		appErr := apperror.From(err)
+		if appErr.Code != apperror.CodeInsufficientStock || appErr.Message != "Insufficient stock for product: Gadget" {
+			t.Errorf("Expected insufficient stock for Gadget, got %v", err)
+		}
+
+		if ts.stock(t, 1) != 10 || ts.orderCount(t) != 0 {
+			t.Errorf("Expected nothing to change, got stock %d and %d orders", ts.stock(t, 1), ts.orderCount(t))
+		}
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, nil, nil)
+
+		_, err := svc.Place(context.Background(), customer, []OrderItemInput{{ProductID: 7, Quantity: 1}})
+
+		appErr := apperror.From(err)
+		if appErr.Code != apperror.CodeBadRequest || appErr.Message != "Product not found: 7" {
+			t.Errorf("Expected 'Product not found: 7', got %v", err)
+		}
+	})
+
+	t.Run("mixed currencies", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, nil, []models.Product{
+			{ID: 1, Name: "Widget", Price: 1099, Currency: "USD", Stock: 10},
+			{ID: 2, Name: "Gadget", Price: 250, Currency: "EUR", Stock: 10},
+		})
+
+		_, err := svc.Place(context.Background(), customer, []OrderItemInput{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}})
+
+		if apperror.CodeOf(err) != apperror.CodeBadRequest {
+			t.Errorf("Expected bad request, got %v", err)
+		}
+	})
+
+	t.Run("total too large", func(t *testing.T) {
+		svc, ts := newTestOrderService(t, nil, []models.Product{
+			{ID: 1, Name: "Widget", Price: math.MaxInt64 / 2, Currency: "USD", Stock: 10},
+			{ID: 2, Name: "Gadget", Price: math.MaxInt64 / 2, Currency: "USD", Stock: 10},
+		})
+
+		for _, items := range [][]OrderItemInput{
+			{{ProductID: 1, Quantity: 3}},
+			{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+		} {
+			_, err := svc.Place(context.Background(), customer, items)
+
+			appErr := apperror.From(err)
+			if appErr.Code != apperror.CodeBadRequest || appErr.Message != "Order total is too large" {
+				t.Errorf("%+v: expected 'Order total is too large', got %v", items, err)
+			}
+		}
+
+		if ts.stock(t, 1) != 10 || ts.orderCount(t) != 0 {
+			t.Errorf("Expected nothing to change, got stock %d and %d orders", ts.stock(t, 1), ts.orderCount(t))
+		}
+	})
+
+	t.Run("stock errors are passed through", func(t *testing.T) {
+		_, ts := newTestOrderService(t, nil, []models.Product{
+			{ID: 1, Name: "Widget", Price: 1099, Currency: "USD", Stock: 10},
+		})
+		svc := NewOrderService(ts.orders, failingStockUnitOfWork{
+			UnitOfWork: repository.NewMemoryUnitOfWork(ts.store),
+			err:        errors.New("connection reset"),
+		}, nil)
+
+		_, err := svc.Place(context.Background(), customer, []OrderItemInput{{ProductID: 1, Quantity: 1}})
+
+		if apperror.CodeOf(err) != apperror.CodeInternal {
+			t.Errorf("Expected internal error, got %v", err)
+		}
+	})
+
+	t.Run("invalid items", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, nil, nil)
+
+		for _, items := range [][]OrderItemInput{nil, {{ProductID: 0, Quantity: 1}}, {{ProductID: 1, Quantity: 0}}} {
+			_, err := svc.Place(context.Background(), customer, items)
+
+			if apperror.CodeOf(err) != apperror.CodeValidation {
+				t.Errorf("%+v: expected validation error, got %v", items, err)
+			}
+		}
+	})
+}
+
+func TestOrderService_List(t *testing.T) {
+	orders := []models.Order{
+		{TransactionID: "txn-1", CustomerID: "user-1"},
+		{TransactionID: "txn-2", CustomerID: "someone-else"},
+	}
+
+	t.Run("customers only see their own orders", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, orders, nil)
+
+		listed, total, err := svc.List(context.Background(), customer, repository.OrderListOptions{CustomerID: "someone-else"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if total != 1 || len(listed) != 1 || listed[0].CustomerID != "user-1" {
+			t.Errorf("Expected only user-1's order, got %+v", listed)
+		}
+	})
+
+	t.Run("staff see every order", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, orders, nil)
+
+		_, total, err := svc.List(context.Background(), Actor{ID: "staff-1", Staff: true}, repository.OrderListOptions{})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if total != 2 {
+			t.Errorf("Expected 2 orders, got %d", total)
+		}
+	})
+}
+
+func TestOrderService_GetByTransactionID(t *testing.T) {
+	orders := []models.Order{{TransactionID: "txn-1", CustomerID: "user-2"}}
+
+	t.Run("hides other customers' orders", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, orders, nil)
+
+		_, err := svc.GetByTransactionID(context.Background(), customer, "txn-1")
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("staff can read any order", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, orders, nil)
+
+		order, err := svc.GetByTransactionID(context.Background(), Actor{ID: "staff-1", Staff: true}, "txn-1")
+
+		if err != nil || order.ID != 1 {
+			t.Errorf("Expected order 1, got %+v, %v", order, err)
+		}
+	})
+}
+
+func TestOrderService_UpdateStatus(t *testing.T) {
+	newOrders := func(status string) []models.Order {
+		return []models.Order{{
+			TransactionID: "txn-1",
+			Status:        status,
+			OrderItems:    []models.OrderItem{{ProductID: 1, Quantity: 3}},
+		}}
+	}
+
+	t.Run("cancelling restocks once", func(t *testing.T) {
+		svc, ts := newTestOrderService(t, newOrders(models.OrderStatusConfirmed), []models.Product{
+			{ID: 1, Name: "Widget", Stock: 2},
+		})
+
+		for i := 0; i < 2; i++ {
+			if err := svc.UpdateStatus(context.Background(), 1, models.OrderStatusCancelled); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+
+		if status := ts.status(t, 1); status != models.OrderStatusCancelled {
+			t.Errorf("Expected cancelled order, got %s", status)
+		}
+
+		if stock := ts.stock(t, 1); stock != 5 {
+			t.Errorf("Expected stock 5, got %d", stock)
+		}
+	})
+
+	t.Run("invalid transition", func(t *testing.T) {
+		svc, ts := newTestOrderService(t, newOrders(models.OrderStatusDelivered), nil)
+
+		err := svc.UpdateStatus(context.Background(), 1, models.OrderStatusPending)
+
+		if apperror.CodeOf(err) != apperror.CodeConflict {
+			t.Errorf("Expected conflict, got %v", err)
+		}
+
+		if status := ts.status(t, 1); status != models.OrderStatusDelivered {
+			t.Errorf("Expected status to stay delivered, got %s", status)
+		}
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, newOrders(models.OrderStatusPending), nil)
+
+		err := svc.UpdateStatus(context.Background(), 1, "lost")
+
+		if apperror.CodeOf(err) != apperror.CodeValidation {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+	})
+
+	t.Run("order not found", func(t *testing.T) {
+		svc, _ := newTestOrderService(t, nil, nil)
+
+		err := svc.UpdateStatus(context.Background(), 1, models.OrderStatusConfirmed)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+}
+
+// recordingMetrics remembers what OrderMetrics was told.
+type recordingMetrics struct {
+	placed      []string
+	transitions []string
+	stockOuts   int
+}
+
+func (m *recordingMetrics) OrderPlaced(order *models.Order) {
+	m.placed = append(m.placed, order.TransactionID)
+}
+
+func (m *recordingMetrics) OrderStatusChanged(from, to string) {
+	m.transitions = append(m.transitions, from+"->"+to)
+}
+
+func (m *recordingMetrics) StockOutRejected() {
+	m.stockOuts++
+}
+
+func TestOrderService_Metrics(t *testing.T) {
+	_, ts := newTestOrderService(t, nil, []models.Product{
+		{ID: 1, Name: "Widget", Price: 1099, Currency: "USD", Stock: 2},
+	})
+	metrics := &recordingMetrics{}
+	svc := NewOrderService(ts.orders, repository.NewMemoryUnitOfWork(ts.store), metrics)
+	ctx := context.Background()
+
+	order, err := svc.Place(ctx, customer, []OrderItemInput{{ProductID: 1, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := svc.Place(ctx, customer, []OrderItemInput{{ProductID: 1, Quantity: 1}}); apperror.CodeOf(err) != apperror.CodeInsufficientStock {
+		t.Fatalf("Expected insufficient stock, got %v", err)
+	}
+	if _, err := svc.Place(ctx, customer, []OrderItemInput{{ProductID: 9, Quantity: 1}}); err == nil {
+		t.Fatal("Expected an unknown product to be rejected")
+	}
+
+	for _, status := range []string{models.OrderStatusCancelled, models.OrderStatusCancelled, models.OrderStatusShipped} {
+		svc.UpdateStatus(ctx, order.ID, status)
+	}
+
+	if len(metrics.placed) != 1 || metrics.placed[0] != order.TransactionID {
+		t.Errorf("Expected one placed order, got %v", metrics.placed)
+	}
+	if metrics.stockOuts != 1 {
+		t.Errorf("Expected one stock-out, got %d", metrics.stockOuts)
+	}
+	if len(metrics.transitions) != 1 || metrics.transitions[0] != "pending->cancelled" {
+		t.Errorf("Expected only the committed cancellation, got %v", metrics.transitions)
+	}
+}
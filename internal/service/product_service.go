@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+)
+
+type ProductInput struct {
+	Name        string
+	Description string
+	Price       models.Money
+	Currency    string // defaults to models.DefaultCurrency
+	Stock       int
+}
+
+// ProductPatch only changes the fields that are set.
+type ProductPatch struct {
+	Name        *string
+	Description *string
+	Price       *models.Money
+	Currency    *string
+	Stock       *int
+}
+
+type ProductService interface {
+	List(ctx context.Context, opts repository.ProductListOptions) ([]models.Product, int64, error)
+	Get(ctx context.Context, id uint) (*models.Product, error)
+	Create(ctx context.Context, input ProductInput) (*models.Product, error)
+	Replace(ctx context.Context, id uint, input ProductInput) (*models.Product, error)
+	Patch(ctx context.Context, id uint, patch ProductPatch) (*models.Product, error)
+	Archive(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+}
+
+type productService struct {
+	products repository.ProductRepository
+}
+
+func NewProductService(products repository.ProductRepository) ProductService {
+	return &productService{products: products}
+}
+
+func (s *productService) List(ctx context.Context, opts repository.ProductListOptions) ([]models.Product, int64, error) {
+	return s.products.List(ctx, opts)
+}
+
+func (s *productService) Get(ctx context.Context, id uint) (*models.Product, error) {
+	return s.products.GetByID(ctx, id)
+}
+
+func (s *productService) Create(ctx context.Context, input ProductInput) (*models.Product, error) {
+	product := &models.Product{}
+	input.applyTo(product)
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+
+	if err := s.products.Create(ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *productService) Replace(ctx context.Context, id uint, input ProductInput) (*models.Product, error) {
+	product, err := s.products.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	input.applyTo(product)
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+
+	if err := s.products.Update(ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Patch writes only the fields set in patch, so stock sold while the patch
+// is applied is kept unless the patch sets the stock itself.
+func (s *productService) Patch(ctx context.Context, id uint, patch ProductPatch) (*models.Product, error) {
+	product, err := s.products.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The patched product is validated as a whole, but only the changes are
+	// written.
+	if patch.Name != nil {
+		product.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		product.Description = *patch.Description
+	}
+	if patch.Price != nil {
+		product.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		product.Currency = *patch.Currency
+	}
+	if patch.Stock != nil {
+		product.Stock = *patch.Stock
+	}
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+
+	if err := s.products.Patch(ctx, id, repository.ProductChanges(patch)); err != nil {
+		return nil, err
+	}
+	return s.products.GetByID(ctx, id)
+}
+
+// Archive hides the product from the catalog. Archiving a product that does
+// not exist is reported as not found.
+func (s *productService) Archive(ctx context.Context, id uint) error {
+	if _, err := s.products.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.products.Delete(ctx, id)
+}
+
+func (s *productService) Restore(ctx context.Context, id uint) error {
+	return s.products.Restore(ctx, id)
+}
+
+func (input ProductInput) applyTo(product *models.Product) {
+	product.Name = input.Name
+	product.Description = input.Description
+	product.Price = input.Price
+	product.Currency = input.Currency
+	if product.Currency == "" {
+		product.Currency = models.DefaultCurrency
+	}
+	product.Stock = input.Stock
+}
+
+func validateProduct(product *models.Product) error {
+	var fields []apperror.FieldError
+	if strings.TrimSpace(product.Name) == "" {
+		fields = append(fields, apperror.FieldError{Field: "name", Rule: "required", Message: "name is required"})
+	}
+	if product.Price < 0 {
+		fields = append(fields, apperror.FieldError{Field: "price", Rule: "min", Message: "price must be at least 0"})
+	}
+	if !models.IsSupportedCurrency(product.Currency) {
+		fields = append(fields, apperror.FieldError{
+			Field:   "currency",
+			Rule:    "oneof",
+			Message: "currency must be one of " + strings.Join(models.SupportedCurrencies, ", "),
+		})
+	}
+	if product.Stock < 0 {
+		fields = append(fields, apperror.FieldError{Field: "stock", Rule: "min", Message: "stock must be at least 0"})
+	}
+
+	if len(fields) > 0 {
+		return apperror.Validation("Validation failed", fields)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorepositorytest/internal/apperror"
+	"gorepositorytest/internal/models"
+	"gorepositorytest/internal/repository"
+)
+
+// newTestProductRepository returns an in-memory repository holding products.
+func newTestProductRepository(t *testing.T, store *repository.MemoryStore, products ...models.Product) repository.ProductRepository {
+	t.Helper()
+	repo := repository.NewMemoryProductRepository(store)
+	for _, product := range products {
+		if err := repo.Create(context.Background(), &product); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+	}
+	return repo
+}
+
+// storedProduct reads product id back without its timestamps, so it can be
+// compared with ==.
+func storedProduct(t *testing.T, repo repository.ProductRepository, id uint) models.Product {
+	t.Helper()
+	product, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to read product %d: %v", id, err)
+	}
+	product.CreatedAt, product.UpdatedAt = time.Time{}, time.Time{}
+	return *product
+}
+
+func TestProductService_Create(t *testing.T) {
+	t.Run("defaults the currency", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore())
+		svc := NewProductService(repo)
+
+		product, err := svc.Create(context.Background(), ProductInput{Name: "Widget", Price: 1099, Stock: 5})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if product.ID != 1 || product.Currency != models.DefaultCurrency {
+			t.Errorf("Expected stored product in %s, got %+v", models.DefaultCurrency, product)
+		}
+	})
+
+	t.Run("rejects invalid products", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore())
+		svc := NewProductService(repo)
+
+		_, err := svc.Create(context.Background(), ProductInput{Name: " ", Price: -1, Currency: "EURO", Stock: -2})
+
+		appErr := apperror.From(err)
+		if appErr.Code != apperror.CodeValidation {
+			t.Fatalf("Expected validation error, got %v", err)
+		}
+
+		fields, _ := appErr.Details.([]apperror.FieldError)
+		if len(fields) != 4 {
+			t.Errorf("Expected 4 field errors, got %+v", fields)
+		}
+
+		if products, _ := repo.GetAll(context.Background()); len(products) != 0 {
+			t.Errorf("Expected no product to be stored, got %d", len(products))
+		}
+	})
+}
+
+func TestProductService_Replace(t *testing.T) {
+	t.Run("replaces every field", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore(),
+			models.Product{ID: 1, Name: "Widget", Description: "Old", Price: 1099, Currency: "EUR", Stock: 5})
+		svc := NewProductService(repo)
+
+		product, err := svc.Replace(context.Background(), 1, ProductInput{Name: "Gadget", Price: 500, Stock: 1})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := models.Product{ID: 1, Name: "Gadget", Price: 500, Currency: models.DefaultCurrency, Stock: 1}
+		if stored := storedProduct(t, repo, 1); product.Name != "Gadget" || stored != expected {
+			t.Errorf("Expected %+v, got %+v", expected, stored)
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		svc := NewProductService(newTestProductRepository(t, repository.NewMemoryStore()))
+
+		_, err := svc.Replace(context.Background(), 9, ProductInput{Name: "Gadget"})
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+}
+
+// sellAfterReadRepository sells 3 units right after the first read, like a
+// checkout racing an update.
+type sellAfterReadRepository struct {
+	repository.ProductRepository
+	sold bool
+}
+
+func (r *sellAfterReadRepository) GetByID(ctx context.Context, id uint) (*models.Product, error) {
+	product, err := r.ProductRepository.GetByID(ctx, id)
+	if err == nil && !r.sold {
+		r.sold = true
+		err = r.ProductRepository.DecrementStock(ctx, id, 3)
+	}
+	return product, err
+}
+
+func TestProductService_Patch(t *testing.T) {
+	t.Run("only changes set fields", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore(),
+			models.Product{ID: 1, Name: "Widget", Description: "Blue", Price: 1099, Currency: "USD", Stock: 5})
+		svc := NewProductService(repo)
+		stock := 9
+
+		_, err := svc.Patch(context.Background(), 1, ProductPatch{Stock: &stock})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := models.Product{ID: 1, Name: "Widget", Description: "Blue", Price: 1099, Currency: "USD", Stock: 9}
+		if stored := storedProduct(t, repo, 1); stored != expected {
+			t.Errorf("Expected %+v, got %+v", expected, stored)
+		}
+	})
+
+	t.Run("keeps stock sold while patching", func(t *testing.T) {
+		repo := &sellAfterReadRepository{ProductRepository: newTestProductRepository(t, repository.NewMemoryStore(),
+			models.Product{ID: 1, Name: "Widget", Description: "Blue", Price: 1099, Currency: "USD", Stock: 5})}
+		svc := NewProductService(repo)
+		name := "Widget Pro"
+
+		product, err := svc.Patch(context.Background(), 1, ProductPatch{Name: &name})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		expected := models.Product{ID: 1, Name: "Widget Pro", Description: "Blue", Price: 1099, Currency: "USD", Stock: 2}
+		if stored := storedProduct(t, repo, 1); stored != expected {
+			t.Errorf("Expected %+v, got %+v", expected, stored)
+		}
+		if product.Stock != 2 {
+			t.Errorf("Expected the response to show stock 2, got %d", product.Stock)
+		}
+	})
+
+	t.Run("rejects an empty name", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore(),
+			models.Product{ID: 1, Name: "Widget", Price: 1099, Currency: "USD", Stock: 5})
+		svc := NewProductService(repo)
+		name := ""
+
+		_, err := svc.Patch(context.Background(), 1, ProductPatch{Name: &name})
+
+		if apperror.CodeOf(err) != apperror.CodeValidation {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+
+		if stored := storedProduct(t, repo, 1); stored.Name != "Widget" {
+			t.Errorf("Expected product to be unchanged, got %+v", stored)
+		}
+	})
+}
+
+func TestProductService_Archive(t *testing.T) {
+	t.Run("archives an existing product", func(t *testing.T) {
+		repo := newTestProductRepository(t, repository.NewMemoryStore(), models.Product{ID: 1, Name: "Widget"})
+		svc := NewProductService(repo)
+
+		if err := svc.Archive(context.Background(), 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if _, err := repo.GetByID(context.Background(), 1); apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected product 1 to be archived, got %v", err)
+		}
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		svc := NewProductService(newTestProductRepository(t, repository.NewMemoryStore()))
+
+		err := svc.Archive(context.Background(), 1)
+
+		if apperror.CodeOf(err) != apperror.CodeNotFound {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+}